@@ -0,0 +1,193 @@
+package alitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+// variablePattern matches a ${name} variable reference.
+var variablePattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// AliCapture extracts a value from a response into a variable:
+// from the body with a json pointer, or from a header by name.
+type AliCapture struct {
+	Body   string `json:"body" yaml:"body"`
+	Header string `json:"header" yaml:"header"`
+}
+
+// Capture extracts the x-ali-capture variables from a response.
+func (o OpenApiResponse) Capture(header http.Header, payload []byte) (map[string]interface{}, error) {
+	variables := make(map[string]interface{}, len(o.AliCapture))
+	var body interface{}
+	bodyDecoded := false
+
+	for _, name := range sortedKeys(o.AliCapture) {
+		capture := o.AliCapture[name]
+
+		switch {
+		case capture.Header != "" && capture.Body != "":
+			return nil, fmt.Errorf("cannot capture %s, set either body or header", name)
+		case capture.Header != "":
+			values, present := header[http.CanonicalHeaderKey(capture.Header)]
+			if !present {
+				return nil, fmt.Errorf("cannot capture %s, header %s is missing", name, capture.Header)
+			}
+			variables[name] = values[0]
+		case capture.Body != "":
+			tokens, err := parseJSONPointer(capture.Body)
+			if err != nil {
+				return nil, fmt.Errorf("cannot capture %s : %w", name, err)
+			}
+			if !bodyDecoded {
+				if len(bytes.TrimSpace(payload)) == 0 {
+					return nil, fmt.Errorf("cannot capture %s, the response has no body", name)
+				}
+				// numbers are kept as written, an id must not turn into 1.2e+07
+				decoder := json.NewDecoder(bytes.NewReader(payload))
+				decoder.UseNumber()
+				if err := decoder.Decode(&body); err != nil {
+					return nil, fmt.Errorf("cannot capture %s, cannot decode the response : %w", name, err)
+				}
+				bodyDecoded = true
+			}
+			value, found := lookupJSONPointer(body, tokens)
+			if !found {
+				return nil, fmt.Errorf("cannot capture %s, nothing at %s in the response", name, capture.Body)
+			}
+			variables[name] = value
+		default:
+			return nil, fmt.Errorf("cannot capture %s, set either body or header", name)
+		}
+	}
+
+	return variables, nil
+}
+
+// Interpolate replaces the ${name} references of x-ali-parameters, x-ali-body
+// and x-ali-response expected payload by the captured variables.
+func (o OpenApiResponse) Interpolate(variables map[string]interface{}) (OpenApiResponse, error) {
+	if len(o.AliParameters) > 0 {
+		parameters := make(map[string]AliParameter, len(o.AliParameters))
+		for name, param := range o.AliParameters {
+			value, err := interpolate(param.Value, variables)
+			if err != nil {
+				return o, err
+			}
+			param.Value = value
+			parameters[name] = param
+		}
+		o.AliParameters = parameters
+	}
+
+	body, err := interpolate(o.AliBody, variables)
+	if err != nil {
+		return o, err
+	}
+	o.AliBody = body
+
+	if o.AliResponse != nil {
+		aliResponse := *o.AliResponse
+		expected, err := interpolate(aliResponse.Expected, variables)
+		if err != nil {
+			return o, err
+		}
+		aliResponse.Expected = expected
+		o.AliResponse = &aliResponse
+	}
+
+	return o, nil
+}
+
+// interpolate copies a decoded value, replacing the variable references of its strings.
+// A string made of a single reference takes the variable value as is, keeping its type.
+func interpolate(value interface{}, variables map[string]interface{}) (interface{}, error) {
+	return transformStrings(value, func(value string) (interface{}, error) {
+		return interpolateString(value, variables)
+	})
+}
+
+// transformStrings copies a decoded value, replacing each of its strings by the result of transform.
+func transformStrings(value interface{}, transform func(string) (interface{}, error)) (interface{}, error) {
+	switch typedValue := value.(type) {
+	case string:
+		return transform(typedValue)
+	case map[string]interface{}:
+		interpolated := make(map[string]interface{}, len(typedValue))
+		for key, child := range typedValue {
+			child, err := transformStrings(child, transform)
+			if err != nil {
+				return nil, err
+			}
+			interpolated[key] = child
+		}
+		return interpolated, nil
+	case []interface{}:
+		interpolated := make([]interface{}, len(typedValue))
+		for index, child := range typedValue {
+			child, err := transformStrings(child, transform)
+			if err != nil {
+				return nil, err
+			}
+			interpolated[index] = child
+		}
+		return interpolated, nil
+	default:
+		return value, nil
+	}
+}
+
+func interpolateString(value string, variables map[string]interface{}) (interface{}, error) {
+	references := variablePattern.FindAllStringSubmatchIndex(value, -1)
+	if len(references) == 0 {
+		return value, nil
+	}
+
+	for _, reference := range references {
+		name := value[reference[2]:reference[3]]
+		if _, found := variables[name]; !found {
+			return nil, fmt.Errorf("variable %s is referenced before being captured", name)
+		}
+	}
+
+	if len(references) == 1 && references[0][0] == 0 && references[0][1] == len(value) {
+		return variables[value[references[0][2]:references[0][3]]], nil
+	}
+
+	return variablePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		return variableString(variables[reference[2:len(reference)-1]])
+	}), nil
+}
+
+// variableString formats a variable embedded in a string, objects and arrays as json.
+func variableString(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(value)
+		if err == nil {
+			return string(encoded)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// captureVariables stores the x-ali-capture variables of the response for the next tests of the run.
+func (o OpenApiResponse) captureVariables(t *testing.T, ctx operationRunContext, statusCode string, header http.Header, payload []byte) {
+	if len(o.AliCapture) == 0 {
+		return
+	}
+
+	variables, err := o.Capture(header, payload)
+
+	if err != nil {
+		t.Fatalf("Cannot capture variables from the response of %s (%s) : %v", ctx.operationID, statusCode, err)
+	}
+
+	for name, value := range variables {
+		ctx.run.variables[name] = value
+		t.Logf("Captured %s = %v", name, value)
+	}
+}
@@ -0,0 +1,184 @@
+package alitest_test
+
+import (
+	_ "embed"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/toolzup/alitest"
+)
+
+//go:embed dataset/capture_specification.yaml
+var captureSpec string
+
+func TestCapture(t *testing.T) {
+	header := http.Header{"Location": {"/pets/12345678"}}
+	payload := []byte(`{"id": 12345678, "tags": [{"name": "dog"}], "owner": null}`)
+
+	tests := []struct {
+		name      string
+		capture   map[string]alitest.AliCapture
+		variables map[string]interface{}
+		err       string
+	}{
+		{
+			name: "body and header",
+			capture: map[string]alitest.AliCapture{
+				"petId":       {Body: "/id"},
+				"tag":         {Body: "/tags/0/name"},
+				"owner":       {Body: "/owner"},
+				"petLocation": {Header: "location"},
+			},
+			variables: map[string]interface{}{"petId": json.Number("12345678"), "tag": "dog", "owner": nil, "petLocation": "/pets/12345678"},
+		},
+		{
+			name:    "missing header",
+			capture: map[string]alitest.AliCapture{"requestId": {Header: "X-Request-Id"}},
+			err:     "cannot capture requestId, header X-Request-Id is missing",
+		},
+		{
+			name:    "missing value",
+			capture: map[string]alitest.AliCapture{"tag": {Body: "/tags/1/name"}},
+			err:     "cannot capture tag, nothing at /tags/1/name in the response",
+		},
+		{
+			name:    "invalid pointer",
+			capture: map[string]alitest.AliCapture{"petId": {Body: "id"}},
+			err:     `cannot capture petId : invalid json pointer "id" : must start with /`,
+		},
+		{
+			name:    "no source",
+			capture: map[string]alitest.AliCapture{"petId": {}},
+			err:     "cannot capture petId, set either body or header",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := alitest.OpenApiResponse{AliCapture: test.capture}
+
+			variables, err := response.Capture(header, payload)
+
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expect error %q, got %v", test.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expect nil error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(variables, test.variables) {
+				t.Fatalf("expect variables %v, got %v", test.variables, variables)
+			}
+		})
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	variables := map[string]interface{}{
+		"petId": json.Number("12345678"),
+		"name":  "Medor",
+		"tags":  []interface{}{"dog"},
+	}
+
+	tests := []struct {
+		name     string
+		response alitest.OpenApiResponse
+		expected alitest.OpenApiResponse
+		err      string
+	}{
+		{
+			name: "parameters, body and expected payload",
+			response: alitest.OpenApiResponse{
+				AliParameters: map[string]alitest.AliParameter{"petId": {Value: "${petId}"}, "q": {Value: "name=${name}"}},
+				AliBody:       map[string]interface{}{"name": "${name}", "tags": "${tags}"},
+				AliResponse:   &alitest.AliResponse{Expected: []interface{}{map[string]interface{}{"id": "${petId}", "label": "${name} ${tags}"}}},
+			},
+			expected: alitest.OpenApiResponse{
+				AliParameters: map[string]alitest.AliParameter{"petId": {Value: json.Number("12345678")}, "q": {Value: "name=Medor"}},
+				AliBody:       map[string]interface{}{"name": "Medor", "tags": []interface{}{"dog"}},
+				AliResponse:   &alitest.AliResponse{Expected: []interface{}{map[string]interface{}{"id": json.Number("12345678"), "label": `Medor ["dog"]`}}},
+			},
+		},
+		{
+			name:     "no reference",
+			response: alitest.OpenApiResponse{AliBody: "$petId {petId}"},
+			expected: alitest.OpenApiResponse{AliBody: "$petId {petId}"},
+		},
+		{
+			name:     "unknown variable",
+			response: alitest.OpenApiResponse{AliParameters: map[string]alitest.AliParameter{"ownerId": {Value: "${ownerId}"}}},
+			err:      "variable ownerId is referenced before being captured",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := test.response.Interpolate(variables)
+
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expect error %q, got %v", test.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expect nil error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(response, test.expected) {
+				t.Fatalf("expect %+v, got %+v", test.expected, response)
+			}
+		})
+	}
+}
+
+func TestRunCapture(t *testing.T) {
+	var calls []string
+
+	integrationSuite, err := alitest.ParseString(captureSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls = append(calls, r.Method+" "+r.URL.RequestURI()+" "+string(body))
+		w.Header().Set("Content-Type", "application/json")
+
+		var err error
+		switch r.Method {
+		case http.MethodPost:
+			w.Header().Set("Location", "/pets/12345678")
+			w.WriteHeader(http.StatusCreated)
+			_, err = w.Write([]byte(`{"id": 12345678, "name": "Medor"}`))
+		case http.MethodPatch:
+			_, err = w.Write([]byte(`{"id": 12345678, "name": "Rex", "location": "/pets/12345678?renamed=true"}`))
+		}
+		if err != nil {
+			t.Errorf("expect nil error, but got %v", err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	integrationSuite.Run(t, alitest.RunParameters{URL: srv.URL})
+
+	expected := []string{
+		`POST /pets {"name":"Medor"}`,
+		`PATCH /pets?id=12345678 {"name":"Rex"}`,
+	}
+
+	if strings.Join(calls, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expect calls %v, but got %v", expected, calls)
+	}
+}
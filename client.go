@@ -0,0 +1,105 @@
+package alitest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime/debug"
+	"time"
+)
+
+// defaultTimeout is the timeout of the client used when RunParameters.Client is not set.
+const defaultTimeout = 10 * time.Second
+
+// Middleware wraps the transport of the suite client, to intercept the requests and the responses.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to the http.RoundTripper interface.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+// newHTTPClient builds the client shared by all the requests of a suite run.
+func newHTTPClient(parameters RunParameters) *http.Client {
+	client := parameters.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout, Transport: parameters.Transport}
+	}
+
+	if len(parameters.Middlewares) == 0 && parameters.Handler == nil {
+		return client
+	}
+
+	// the given client is left untouched
+	wrapped := *client
+	if parameters.Handler != nil {
+		wrapped.Transport = handlerTransport{handler: parameters.Handler}
+	}
+	transport := wrapped.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for index := len(parameters.Middlewares) - 1; index >= 0; index-- {
+		transport = parameters.Middlewares[index](transport)
+	}
+	wrapped.Transport = transport
+	return &wrapped
+}
+
+// closeBody drains and closes a response body, so that the connection can be reused.
+func closeBody(response *http.Response) {
+	_, _ = io.Copy(io.Discard, response.Body)
+	_ = response.Body.Close()
+}
+
+// handlerTransport serves the requests in process with a handler, without any network.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+// RoundTrip serves the request with the handler. A panic of the handler is returned as an error,
+// with the stack of the handler. The handler runs until the context of the request is done,
+// e.g. on the timeout of the client.
+func (t handlerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	serverRequest := request.Clone(request.Context())
+	serverRequest.RequestURI = request.URL.RequestURI()
+	serverRequest.RemoteAddr = "192.0.2.1:1234"
+	if serverRequest.Host == "" {
+		serverRequest.Host = request.URL.Host
+	}
+	// a server request only has the path and query of its url, as with httptest.NewRequest
+	serverRequest.URL = &url.URL{Path: request.URL.Path, RawPath: request.URL.RawPath, RawQuery: request.URL.RawQuery}
+	if serverRequest.Body == nil {
+		serverRequest.Body = http.NoBody
+	}
+
+	recorder := httptest.NewRecorder()
+	served := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				served <- fmt.Errorf("handler panicked on %s %s : %v\n%s", request.Method, request.URL.RequestURI(), recovered, debug.Stack())
+			}
+		}()
+		t.handler.ServeHTTP(recorder, serverRequest)
+		served <- nil
+	}()
+
+	select {
+	case err := <-served:
+		if err != nil {
+			return nil, err
+		}
+	case <-request.Context().Done():
+		return nil, fmt.Errorf("handler did not answer %s %s : %w", request.Method, request.URL.RequestURI(), request.Context().Err())
+	}
+
+	response := recorder.Result()
+	response.Request = request
+	return response, nil
+}
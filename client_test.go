@@ -0,0 +1,118 @@
+package alitest_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/toolzup/alitest"
+)
+
+// trackedBody records whether a response body was closed.
+type trackedBody struct {
+	io.ReadCloser
+	closed *int
+}
+
+func (b trackedBody) Close() error {
+	*b.closed++
+	return b.ReadCloser.Close()
+}
+
+func TestRunClient(t *testing.T) {
+	var events []string
+	var responses, closed int
+
+	integrationSuite, err := alitest.ParseString(skipSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Trace-Id") != "trace" {
+			t.Errorf("expect the middleware header, but got %q", r.Header.Get("X-Trace-Id"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte(`[]`)); err != nil {
+			t.Errorf("expect nil error, but got %v", err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client := &http.Client{Transport: alitest.RoundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		response, err := http.DefaultTransport.RoundTrip(request)
+		if err == nil {
+			responses++
+			response.Body = trackedBody{ReadCloser: response.Body, closed: &closed}
+		}
+		return response, err
+	})}
+
+	middleware := func(name string) alitest.Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return alitest.RoundTripperFunc(func(request *http.Request) (*http.Response, error) {
+				events = append(events, name+" request")
+				request.Header.Set("X-Trace-Id", "trace")
+				response, err := next.RoundTrip(request)
+				events = append(events, name+" response")
+				return response, err
+			})
+		}
+	}
+
+	integrationSuite.Run(t, alitest.RunParameters{
+		URL:         srv.URL,
+		Client:      client,
+		Middlewares: []alitest.Middleware{middleware("outer"), middleware("inner")},
+	})
+
+	if responses == 0 {
+		t.Fatalf("expect the requests to be sent by the given client")
+	}
+
+	if closed != responses {
+		t.Fatalf("expect the %d response bodies to be closed, but %d were", responses, closed)
+	}
+
+	if strings.Join(events[:4], ",") != "outer request,inner request,inner response,outer response" {
+		t.Fatalf("expect the middlewares to be chained in order, but got %v", events)
+	}
+
+	if client.Transport == nil {
+		t.Fatalf("expect the given client to be left untouched")
+	}
+}
+
+func TestRunTransport(t *testing.T) {
+	var requests int
+
+	integrationSuite, err := alitest.ParseString(skipSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write([]byte(`[]`)); err != nil {
+			t.Errorf("expect nil error, but got %v", err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	integrationSuite.Run(t, alitest.RunParameters{
+		URL: srv.URL,
+		Transport: alitest.RoundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			requests++
+			return http.DefaultTransport.RoundTrip(request)
+		}),
+	})
+
+	if requests == 0 {
+		t.Fatalf("expect the requests to be sent through the given transport")
+	}
+}
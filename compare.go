@@ -0,0 +1,329 @@
+package alitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	diff "github.com/nsf/jsondiff"
+)
+
+// Array comparison modes.
+const (
+	// OrderedArrays compares the items by position, the default
+	OrderedArrays = "ordered"
+	// UnorderedArrays expects the same items, in any order
+	UnorderedArrays = "unordered"
+	// ContainsArrays expects at least the given items, in any order
+	ContainsArrays = "contains"
+)
+
+// AliArrays sets how the returned arrays are compared to the expected ones.
+type AliArrays struct {
+	// Mode is OrderedArrays, UnorderedArrays or ContainsArrays, ordered when empty
+	Mode string `json:"mode" yaml:"mode"`
+	// Length, MinLength and MaxLength check the number of returned items
+	Length    *int `json:"length" yaml:"length"`
+	MinLength *int `json:"minLength" yaml:"minLength"`
+	MaxLength *int `json:"maxLength" yaml:"maxLength"`
+}
+
+func (a AliArrays) isSet() bool {
+	return a.Mode != "" || a.Length != nil || a.MinLength != nil || a.MaxLength != nil
+}
+
+// arrayRule applies array options to the arrays at a json pointer.
+type arrayRule struct {
+	tokens []string
+	arrays AliArrays
+}
+
+// payloadComparison lines up the returned arrays with the expected ones before the matchers
+// and the diff: the unordered items are paired with the expected items they match, so that
+// the diff only shows the actual differences. The lengths are checked apart, on the returned
+// arrays only. The failed checks are collected in mismatches.
+type payloadComparison struct {
+	acceptAdditionalProps bool
+	arrays                AliArrays
+	rules                 []arrayRule
+	mismatches            []string
+}
+
+// hasArrayOptions tells if the returned arrays must be aligned before the diff.
+func (r AliResponse) hasArrayOptions() bool {
+	return r.Arrays.isSet() || len(r.ArraysAt) > 0
+}
+
+// alignPayloads applies the array options of the response to the returned payload.
+func (r AliResponse) alignPayloads(actualPayload, expectedPayload []byte) ([]byte, []string, error) {
+	comparison := &payloadComparison{acceptAdditionalProps: r.AcceptAdditionalProps, arrays: r.Arrays}
+
+	for _, pointer := range sortedKeys(r.ArraysAt) {
+		tokens, err := parseJSONPointer(pointer)
+		if err != nil {
+			return nil, nil, err
+		}
+		comparison.rules = append(comparison.rules, arrayRule{tokens: tokens, arrays: r.ArraysAt[pointer]})
+	}
+
+	for _, arrays := range append([]AliArrays{r.Arrays}, mapValues(r.ArraysAt)...) {
+		switch arrays.Mode {
+		case "", OrderedArrays, UnorderedArrays, ContainsArrays:
+		default:
+			return nil, nil, fmt.Errorf("unknown array mode %s", arrays.Mode)
+		}
+	}
+
+	var actual, expected interface{}
+	if err := json.Unmarshal(actualPayload, &actual); err != nil {
+		return nil, nil, fmt.Errorf("cannot decode the returned payload : %w", err)
+	}
+	if err := json.Unmarshal(expectedPayload, &expected); err != nil {
+		return nil, nil, fmt.Errorf("cannot decode the expected payload : %w", err)
+	}
+
+	comparison.checkLengths(actual, nil)
+	actualPayload, err := json.Marshal(comparison.align(expected, actual, nil))
+	return actualPayload, comparison.mismatches, err
+}
+
+// checkLengths checks the length options against every returned array, whether or not
+// the expected payload describes it.
+func (c *payloadComparison) checkLengths(actual interface{}, tokens []string) {
+	switch typedActual := actual.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(typedActual) {
+			c.checkLengths(typedActual[key], childTokens(tokens, key))
+		}
+	case []interface{}:
+		arrays := c.arraysAt(tokens)
+
+		switch {
+		case arrays.Length != nil && len(typedActual) != *arrays.Length:
+			c.mismatch(tokens, "length", fmt.Sprintf("%d items, expect %d", len(typedActual), *arrays.Length))
+		case arrays.MinLength != nil && len(typedActual) < *arrays.MinLength:
+			c.mismatch(tokens, "length", fmt.Sprintf("%d items, expect at least %d", len(typedActual), *arrays.MinLength))
+		case arrays.MaxLength != nil && len(typedActual) > *arrays.MaxLength:
+			c.mismatch(tokens, "length", fmt.Sprintf("%d items, expect at most %d", len(typedActual), *arrays.MaxLength))
+		}
+
+		for index, item := range typedActual {
+			c.checkLengths(item, childTokens(tokens, strconv.Itoa(index)))
+		}
+	}
+}
+
+// align returns a copy of the actual value, with its arrays lined up with the expected ones.
+func (c *payloadComparison) align(expected, actual interface{}, tokens []string) interface{} {
+	if _, _, isMatcher := matcher(expected); isMatcher {
+		return actual
+	}
+
+	switch typedExpected := expected.(type) {
+	case map[string]interface{}:
+		typedActual, isObject := actual.(map[string]interface{})
+		if !isObject {
+			return actual
+		}
+		aligned := make(map[string]interface{}, len(typedActual))
+		for key, child := range typedActual {
+			aligned[key] = child
+			if expectedChild, present := expectedValue(typedExpected, key); present {
+				aligned[key] = c.align(expectedChild, child, childTokens(tokens, key))
+			}
+		}
+		return aligned
+	case []interface{}:
+		typedActual, isArray := actual.([]interface{})
+		if !isArray {
+			return actual
+		}
+		return c.alignArrays(typedExpected, typedActual, tokens)
+	default:
+		return actual
+	}
+}
+
+func (c *payloadComparison) alignArrays(expected, actual []interface{}, tokens []string) interface{} {
+	arrays := c.arraysAt(tokens)
+
+	if arrays.Mode == "" || arrays.Mode == OrderedArrays {
+		aligned := make([]interface{}, len(actual))
+		copy(aligned, actual)
+		for index := 0; index < len(expected) && index < len(actual); index++ {
+			aligned[index] = c.align(expected[index], actual[index], childTokens(tokens, strconv.Itoa(index)))
+		}
+		return aligned
+	}
+
+	pairs := c.pairItems(expected, actual, tokens)
+
+	used := make([]bool, len(actual))
+	for index, candidate := range pairs {
+		if candidate < 0 {
+			c.mismatch(childTokens(tokens, strconv.Itoa(index)), arrays.Mode, "no matching item")
+			continue
+		}
+		used[candidate] = true
+	}
+
+	var remaining []interface{}
+	for candidate, item := range actual {
+		if !used[candidate] {
+			remaining = append(remaining, item)
+		}
+	}
+
+	// the paired items are lined up with the expected ones, the others are left for the diff
+	aligned := make([]interface{}, 0, len(actual))
+	for index, candidate := range pairs {
+		switch {
+		case candidate >= 0:
+			aligned = append(aligned, c.align(expected[index], actual[candidate], childTokens(tokens, strconv.Itoa(index))))
+		case arrays.Mode == UnorderedArrays && len(remaining) > 0:
+			aligned = append(aligned, remaining[0])
+			remaining = remaining[1:]
+		}
+	}
+	if arrays.Mode == UnorderedArrays {
+		aligned = append(aligned, remaining...)
+	}
+	return aligned
+}
+
+// pairItems pairs as many expected items as possible with distinct returned items they match.
+// It returns the returned item of each expected item, -1 when there is none. The pairs are a
+// maximum bipartite matching, so that a loose expected item, e.g. a $any matcher, does not
+// take the only returned item a stricter expected item matches.
+func (c *payloadComparison) pairItems(expected, actual []interface{}, tokens []string) []int {
+	compatible := make([][]bool, len(expected))
+	for index, item := range expected {
+		compatible[index] = make([]bool, len(actual))
+		for candidate := range actual {
+			compatible[index][candidate] = c.matches(item, actual[candidate], childTokens(tokens, strconv.Itoa(index)))
+		}
+	}
+
+	// owners holds the expected item paired with each returned item, -1 when there is none
+	owners := make([]int, len(actual))
+	for candidate := range owners {
+		owners[candidate] = -1
+	}
+
+	// augment pairs the expected item, moving the previous pairs to other candidates when needed
+	var augment func(index int, visited []bool) bool
+	augment = func(index int, visited []bool) bool {
+		for candidate := range actual {
+			if !compatible[index][candidate] || visited[candidate] {
+				continue
+			}
+			visited[candidate] = true
+			if owners[candidate] < 0 || augment(owners[candidate], visited) {
+				owners[candidate] = index
+				return true
+			}
+		}
+		return false
+	}
+
+	for index := range expected {
+		augment(index, make([]bool, len(actual)))
+	}
+
+	pairs := make([]int, len(expected))
+	for index := range pairs {
+		pairs[index] = -1
+	}
+	for candidate, index := range owners {
+		if index >= 0 {
+			pairs[index] = candidate
+		}
+	}
+	return pairs
+}
+
+// matches tells if a returned value satisfies an expected one.
+func (c *payloadComparison) matches(expected, actual interface{}, tokens []string) bool {
+	comparison := &payloadComparison{acceptAdditionalProps: c.acceptAdditionalProps, arrays: c.arrays, rules: c.rules}
+	actual = comparison.align(expected, actual, tokens)
+
+	var mismatches []string
+	expected = applyMatchers(expected, actual, "", &mismatches)
+	if len(comparison.mismatches) > 0 || len(mismatches) > 0 {
+		return false
+	}
+
+	expectedPayload, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+	actualPayload, err := json.Marshal(actual)
+	if err != nil {
+		return false
+	}
+
+	opt := diff.DefaultJSONOptions()
+	res, _ := diff.Compare(actualPayload, expectedPayload, &opt)
+	return res == diff.FullMatch || (res == diff.SupersetMatch && c.acceptAdditionalProps)
+}
+
+// arraysAt returns the array options of the array at the given location.
+// The last rule matching the pointer wins over the global options.
+func (c *payloadComparison) arraysAt(tokens []string) AliArrays {
+	arrays := c.arrays
+	for _, rule := range c.rules {
+		if pointerMatches(rule.tokens, tokens) {
+			arrays = rule.arrays
+		}
+	}
+	return arrays
+}
+
+func (c *payloadComparison) mismatch(tokens []string, keyword, message string) {
+	var pointer strings.Builder
+	for _, token := range tokens {
+		pointer.WriteString("/" + escapeJSONPointer(token))
+	}
+	c.mismatches = append(c.mismatches, fmt.Sprintf("#%s (%s): %s", pointer.String(), keyword, message))
+}
+
+// pointerMatches tells if the pointer tokens target the location, a wildcard token matches any token.
+func pointerMatches(pointer, location []string) bool {
+	if len(pointer) != len(location) {
+		return false
+	}
+	for index, token := range pointer {
+		if token != pointerWildcard && token != location[index] {
+			return false
+		}
+	}
+	return true
+}
+
+func mapValues[T any](values map[string]T) []T {
+	result := make([]T, 0, len(values))
+	for _, key := range sortedKeys(values) {
+		result = append(result, values[key])
+	}
+	return result
+}
+
+// expectedValue returns the expected value of a returned key, written with an escaped key or not.
+func expectedValue(expected map[string]interface{}, key string) (interface{}, bool) {
+	if value, present := expected[key]; present {
+		return value, true
+	}
+	if strings.HasPrefix(key, "$") {
+		value, present := expected["$"+key]
+		return value, present
+	}
+	return nil, false
+}
+
+// childTokens returns the location of a child, without sharing the parent tokens.
+func childTokens(tokens []string, token string) []string {
+	child := make([]string, len(tokens), len(tokens)+1)
+	copy(child, tokens)
+	return append(child, token)
+}
@@ -0,0 +1,320 @@
+package alitest_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/toolzup/alitest"
+)
+
+func TestCompareArrays(t *testing.T) {
+	two, three := 2, 3
+
+	testCases := []struct {
+		description  string
+		response     alitest.AliResponse
+		bodyResponse []byte
+		identical    bool
+	}{
+		{
+			description: "ordered array mismatch",
+			response: alitest.AliResponse{
+				Expected: []interface{}{"Medor", "Rex"},
+			},
+			bodyResponse: []byte(`["Rex", "Medor"]`),
+			identical:    false,
+		},
+		{
+			description: "unordered array match",
+			response: alitest.AliResponse{
+				Expected: []interface{}{"Medor", "Rex"},
+				Arrays:   alitest.AliArrays{Mode: alitest.UnorderedArrays},
+			},
+			bodyResponse: []byte(`["Rex", "Medor"]`),
+			identical:    true,
+		},
+		{
+			description: "unordered array with a loose item before a strict one",
+			response: alitest.AliResponse{
+				Expected: []interface{}{map[string]interface{}{"$any": true}, "Medor"},
+				Arrays:   alitest.AliArrays{Mode: alitest.UnorderedArrays},
+			},
+			bodyResponse: []byte(`["Medor", "Rex"]`),
+			identical:    true,
+		},
+		{
+			description: "contained items with a loose item before a strict one",
+			response: alitest.AliResponse{
+				Expected: []interface{}{map[string]interface{}{"$type": "string"}, "Rex"},
+				Arrays:   alitest.AliArrays{Mode: alitest.ContainsArrays},
+			},
+			bodyResponse: []byte(`[5, "Rex", "Medor"]`),
+			identical:    true,
+		},
+		{
+			description: "unordered array with a missing item",
+			response: alitest.AliResponse{
+				Expected: []interface{}{"Medor", "Rex"},
+				Arrays:   alitest.AliArrays{Mode: alitest.UnorderedArrays},
+			},
+			bodyResponse: []byte(`["Rex", "Idefix"]`),
+			identical:    false,
+		},
+		{
+			description: "unordered array with an extra item",
+			response: alitest.AliResponse{
+				Expected: []interface{}{"Medor", "Rex"},
+				Arrays:   alitest.AliArrays{Mode: alitest.UnorderedArrays},
+			},
+			bodyResponse: []byte(`["Rex", "Idefix", "Medor"]`),
+			identical:    false,
+		},
+		{
+			description: "unordered array of objects with additional props",
+			response: alitest.AliResponse{
+				Expected: []interface{}{
+					map[string]interface{}{"name": "Medor"},
+					map[string]interface{}{"name": "Rex"},
+				},
+				Arrays:                alitest.AliArrays{Mode: alitest.UnorderedArrays},
+				AcceptAdditionalProps: true,
+			},
+			bodyResponse: []byte(`[{"id": 2, "name": "Rex"}, {"id": 1, "name": "Medor"}]`),
+			identical:    true,
+		},
+		{
+			description: "unordered array with matchers",
+			response: alitest.AliResponse{
+				Expected: []interface{}{
+					map[string]interface{}{"id": map[string]interface{}{"$type": "integer"}, "name": "Medor"},
+					map[string]interface{}{"id": map[string]interface{}{"$type": "integer"}, "name": "Rex"},
+				},
+				Arrays: alitest.AliArrays{Mode: alitest.UnorderedArrays},
+			},
+			bodyResponse: []byte(`[{"id": 2, "name": "Rex"}, {"id": 1, "name": "Medor"}]`),
+			identical:    true,
+		},
+		{
+			description: "contains match",
+			response: alitest.AliResponse{
+				Expected: []interface{}{"Medor"},
+				Arrays:   alitest.AliArrays{Mode: alitest.ContainsArrays},
+			},
+			bodyResponse: []byte(`["Rex", "Medor", "Idefix"]`),
+			identical:    true,
+		},
+		{
+			description: "contains mismatch",
+			response: alitest.AliResponse{
+				Expected: []interface{}{"Medor", "Pluto"},
+				Arrays:   alitest.AliArrays{Mode: alitest.ContainsArrays},
+			},
+			bodyResponse: []byte(`["Rex", "Medor", "Idefix"]`),
+			identical:    false,
+		},
+		{
+			description: "contains the same item twice",
+			response: alitest.AliResponse{
+				Expected: []interface{}{"Medor", "Medor"},
+				Arrays:   alitest.AliArrays{Mode: alitest.ContainsArrays},
+			},
+			bodyResponse: []byte(`["Rex", "Medor"]`),
+			identical:    false,
+		},
+		{
+			description: "length match",
+			response: alitest.AliResponse{
+				Expected: []interface{}{"Medor"},
+				Arrays:   alitest.AliArrays{Mode: alitest.ContainsArrays, Length: &three},
+			},
+			bodyResponse: []byte(`["Rex", "Medor", "Idefix"]`),
+			identical:    true,
+		},
+		{
+			description: "length mismatch",
+			response: alitest.AliResponse{
+				Expected: []interface{}{"Medor"},
+				Arrays:   alitest.AliArrays{Mode: alitest.ContainsArrays, Length: &two},
+			},
+			bodyResponse: []byte(`["Rex", "Medor", "Idefix"]`),
+			identical:    false,
+		},
+		{
+			description: "min length mismatch",
+			response: alitest.AliResponse{
+				Expected: []interface{}{"Medor"},
+				Arrays:   alitest.AliArrays{Mode: alitest.ContainsArrays, MinLength: &two},
+			},
+			bodyResponse: []byte(`["Medor"]`),
+			identical:    false,
+		},
+		{
+			description: "max length mismatch",
+			response: alitest.AliResponse{
+				Expected: []interface{}{"Medor"},
+				Arrays:   alitest.AliArrays{Mode: alitest.ContainsArrays, MaxLength: &two},
+			},
+			bodyResponse: []byte(`["Rex", "Medor", "Idefix"]`),
+			identical:    false,
+		},
+		{
+			description: "unordered array at a pointer",
+			response: alitest.AliResponse{
+				Expected: map[string]interface{}{"names": []interface{}{"Medor", "Rex"}, "ids": []interface{}{1, 2}},
+				ArraysAt: map[string]alitest.AliArrays{"/names": {Mode: alitest.UnorderedArrays}},
+			},
+			bodyResponse: []byte(`{"names": ["Rex", "Medor"], "ids": [1, 2]}`),
+			identical:    true,
+		},
+		{
+			description: "ordered array outside of the pointer",
+			response: alitest.AliResponse{
+				Expected: map[string]interface{}{"names": []interface{}{"Medor", "Rex"}, "ids": []interface{}{1, 2}},
+				ArraysAt: map[string]alitest.AliArrays{"/names": {Mode: alitest.UnorderedArrays}},
+			},
+			bodyResponse: []byte(`{"names": ["Rex", "Medor"], "ids": [2, 1]}`),
+			identical:    false,
+		},
+		{
+			description: "pointer overrides the global mode",
+			response: alitest.AliResponse{
+				Expected: map[string]interface{}{"names": []interface{}{"Medor", "Rex"}, "ids": []interface{}{1, 2}},
+				Arrays:   alitest.AliArrays{Mode: alitest.UnorderedArrays},
+				ArraysAt: map[string]alitest.AliArrays{"/ids": {Mode: alitest.OrderedArrays}},
+			},
+			bodyResponse: []byte(`{"names": ["Rex", "Medor"], "ids": [2, 1]}`),
+			identical:    false,
+		},
+		{
+			description: "wildcard pointer",
+			response: alitest.AliResponse{
+				Expected: []interface{}{
+					map[string]interface{}{"name": "Medor", "tags": []interface{}{"dog", "brown"}},
+				},
+				ArraysAt: map[string]alitest.AliArrays{"/*/tags": {Mode: alitest.UnorderedArrays, Length: &two}},
+			},
+			bodyResponse: []byte(`[{"name": "Medor", "tags": ["brown", "dog"]}]`),
+			identical:    true,
+		},
+		{
+			description: "root pointer",
+			response: alitest.AliResponse{
+				Expected: []interface{}{"Medor"},
+				ArraysAt: map[string]alitest.AliArrays{"": {Mode: alitest.ContainsArrays}},
+			},
+			bodyResponse: []byte(`["Rex", "Medor"]`),
+			identical:    true,
+		},
+		{
+			description: "unordered array under an escaped key",
+			response: alitest.AliResponse{
+				Expected: map[string]interface{}{"$$items": []interface{}{"Medor", "Rex"}},
+				Arrays:   alitest.AliArrays{Mode: alitest.UnorderedArrays},
+			},
+			bodyResponse: []byte(`{"$items": ["Rex", "Medor"]}`),
+			identical:    true,
+		},
+		{
+			description: "length mismatch under a matcher",
+			response: alitest.AliResponse{
+				Expected: map[string]interface{}{"items": map[string]interface{}{"$type": "array"}},
+				ArraysAt: map[string]alitest.AliArrays{"/items": {Length: &two}},
+			},
+			bodyResponse: []byte(`{"items": ["Rex", "Medor", "Idefix", "Pluto"]}`),
+			identical:    false,
+		},
+		{
+			description: "length mismatch of an array missing from the expected payload",
+			response: alitest.AliResponse{
+				Expected:              map[string]interface{}{},
+				AcceptAdditionalProps: true,
+				ArraysAt:              map[string]alitest.AliArrays{"/items": {Length: &two}},
+			},
+			bodyResponse: []byte(`{"items": ["Rex", "Medor", "Idefix", "Pluto"]}`),
+			identical:    false,
+		},
+		{
+			description: "length match without expected payload",
+			response: alitest.AliResponse{
+				ArraysAt: map[string]alitest.AliArrays{"/items": {Length: &two}},
+			},
+			bodyResponse: []byte(`{"items": ["Rex", "Medor"]}`),
+			identical:    true,
+		},
+		{
+			description: "length mismatch without expected payload",
+			response: alitest.AliResponse{
+				ArraysAt: map[string]alitest.AliArrays{"/items": {Length: &two}},
+			},
+			bodyResponse: []byte(`{"items": ["Rex", "Medor", "Idefix", "Pluto"]}`),
+			identical:    false,
+		},
+		{
+			description: "unknown mode",
+			response: alitest.AliResponse{
+				Expected: []interface{}{"Medor"},
+				Arrays:   alitest.AliArrays{Mode: "sorted"},
+			},
+			bodyResponse: []byte(`["Medor"]`),
+			identical:    false,
+		},
+		{
+			description: "malformed pointer",
+			response: alitest.AliResponse{
+				Expected: []interface{}{"Medor"},
+				ArraysAt: map[string]alitest.AliArrays{"names": {Mode: alitest.UnorderedArrays}},
+			},
+			bodyResponse: []byte(`["Medor"]`),
+			identical:    false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.description, func(t *testing.T) {
+			identical, details := testCase.response.Compare(testCase.bodyResponse)
+
+			if identical != testCase.identical {
+				t.Errorf("Expect identical to be %t, but is %t", testCase.identical, identical)
+			}
+
+			t.Log(details)
+		})
+	}
+}
+
+// TestRunArrayOptionsOnly ensures the array options are checked without an expected payload.
+func TestRunArrayOptionsOnly(t *testing.T) {
+	output := runFailingTest(t, func(t *testing.T) {
+		integrationSuite, err := alitest.ParseString(`
+openapi: 3.0.1
+info:
+  title: array options
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      responses:
+        200:
+          description: the pets
+          x-ali-response:
+            arraysAt:
+              /items:
+                maxLength: 2
+`)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"items": ["Rex", "Medor", "Idefix"]}`))
+		})
+
+		integrationSuite.Run(t, alitest.RunParameters{Handler: handler})
+	})
+
+	if !strings.Contains(output, "#/items (length): 3 items, expect at most 2") {
+		t.Errorf("expect the length failure in the output, but got :\n%s", output)
+	}
+}
@@ -0,0 +1,147 @@
+package alitest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/toolzup/alitest"
+)
+
+func TestRunHandler(t *testing.T) {
+	var calls []string
+
+	integrationSuite, err := alitest.ParseString(captureSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pets", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls = append(calls, r.Method+" "+r.Host+r.RequestURI+" "+string(body))
+		if r.URL.IsAbs() {
+			t.Errorf("expect a server request url without scheme and host, but got %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		var err error
+		switch r.Method {
+		case http.MethodPost:
+			w.Header().Set("Location", "/pets/12345678")
+			w.WriteHeader(http.StatusCreated)
+			_, err = w.Write([]byte(`{"id": 12345678, "name": "Medor"}`))
+		case http.MethodPatch:
+			_, err = w.Write([]byte(`{"id": 12345678, "name": "Rex", "location": "/pets/12345678?renamed=true"}`))
+		}
+		if err != nil {
+			t.Errorf("expect nil error, but got %v", err)
+		}
+	})
+
+	integrationSuite.Run(t, alitest.RunParameters{Handler: mux})
+
+	expected := []string{
+		`POST localhost/pets {"name":"Medor"}`,
+		`PATCH localhost/pets?id=12345678 {"name":"Rex"}`,
+	}
+
+	if strings.Join(calls, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expect calls %v, but got %v", expected, calls)
+	}
+}
+
+func TestRunHandlerPanic(t *testing.T) {
+	var failure error
+
+	integrationSuite, err := alitest.ParseString(skipSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("nil pet store")
+	})
+
+	// the middleware turns the failure into a valid response, to inspect it without failing the test
+	recovery := func(next http.RoundTripper) http.RoundTripper {
+		return alitest.RoundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			response, err := next.RoundTrip(request)
+			if err == nil {
+				return response, nil
+			}
+			failure = err
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`[]`)),
+				Request:    request,
+			}, nil
+		})
+	}
+
+	integrationSuite.Run(t, alitest.RunParameters{Handler: handler, Middlewares: []alitest.Middleware{recovery}})
+
+	if failure == nil {
+		t.Fatalf("expect the panic to be reported as an error")
+	}
+
+	if !strings.HasPrefix(failure.Error(), "handler panicked on GET /pets : nil pet store") {
+		t.Fatalf("expect the panic message, but got %v", failure)
+	}
+
+	if !strings.Contains(failure.Error(), "handler_test.go") {
+		t.Fatalf("expect the stack of the handler, but got %v", failure)
+	}
+}
+
+func TestRunHandlerTimeout(t *testing.T) {
+	var failure error
+
+	integrationSuite, err := alitest.ParseString(skipSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	// the handler hangs, whatever the context of the request
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+
+	// the middleware turns the failure into a valid response, to inspect it without failing the test
+	recovery := func(next http.RoundTripper) http.RoundTripper {
+		return alitest.RoundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			response, err := next.RoundTrip(request)
+			if err == nil {
+				return response, nil
+			}
+			failure = err
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`[]`)),
+				Request:    request,
+			}, nil
+		})
+	}
+
+	integrationSuite.Run(t, alitest.RunParameters{
+		Client:      &http.Client{Timeout: 50 * time.Millisecond},
+		Handler:     handler,
+		Middlewares: []alitest.Middleware{recovery},
+	})
+
+	if !errors.Is(failure, context.DeadlineExceeded) {
+		t.Fatalf("expect the handler to time out, but got %v", failure)
+	}
+}
@@ -0,0 +1,188 @@
+package alitest
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type OpenApiHeader struct {
+	Description string  `json:"description" yaml:"description"`
+	Required    bool    `json:"required" yaml:"required"`
+	Schema      *Schema `json:"schema" yaml:"schema"`
+}
+
+// AliHeader is an expected response header value: an exact value, or a regular expression.
+type AliHeader struct {
+	Value   string `json:"value" yaml:"value"`
+	Pattern string `json:"pattern" yaml:"pattern"`
+
+	// pattern is the compiled Pattern, compiled when the document is parsed
+	pattern *regexp.Regexp
+}
+
+// compilePattern returns the compiled Pattern, compiling it on first use.
+func (h *AliHeader) compilePattern() (*regexp.Regexp, error) {
+	if h.pattern != nil {
+		return h.pattern, nil
+	}
+	pattern, err := regexp.Compile(h.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s : %v", h.Pattern, err)
+	}
+	h.pattern = pattern
+	return pattern, nil
+}
+
+// compileHeaderPatterns compiles the patterns of the expected headers of every response test data.
+func (d OpenApiDocument) compileHeaderPatterns() error {
+	for _, name := range sortedKeys(d.Components.Responses) {
+		if err := d.Components.Responses[name].compileHeaderPatterns(); err != nil {
+			return fmt.Errorf("invalid x-ali-response of the response %s : %w", name, err)
+		}
+	}
+
+	for _, path := range sortedKeys(d.Paths) {
+		for _, op := range d.Paths[path].operations() {
+			for _, statusCode := range op.operation.Responses.StatusCodes() {
+				if err := op.operation.Responses[statusCode].compileHeaderPatterns(); err != nil {
+					return fmt.Errorf("invalid x-ali-response of %s %s (%s) : %w", op.verb, path, statusCode, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (o *OpenApiResponse) compileHeaderPatterns() error {
+	if o == nil {
+		return nil
+	}
+
+	responses := []*AliResponse{o.AliResponse}
+	for _, aliCase := range o.AliCases {
+		responses = append(responses, aliCase.Response)
+	}
+
+	for _, response := range responses {
+		if response == nil {
+			continue
+		}
+		for _, name := range sortedKeys(response.Headers) {
+			header := response.Headers[name]
+			if header.Pattern == "" {
+				continue
+			}
+			if _, err := header.compilePattern(); err != nil {
+				return fmt.Errorf("header %s has an %w", name, err)
+			}
+			response.Headers[name] = header
+		}
+	}
+	return nil
+}
+
+// ValidateHeaders checks the returned headers against the documented ones:
+// presence of the required headers, and schema of their values.
+func (o OpenApiResponse) ValidateHeaders(header http.Header) []string {
+	var messages []string
+
+	for _, name := range sortedKeys(o.Headers) {
+		documented := o.Headers[name]
+		// Content-Type is described by the response content
+		if http.CanonicalHeaderKey(name) == "Content-Type" {
+			continue
+		}
+
+		values, present := header[http.CanonicalHeaderKey(name)]
+		if !present {
+			if documented.Required {
+				messages = append(messages, fmt.Sprintf("header %s is required but missing", name))
+			}
+			continue
+		}
+
+		if documented.Schema == nil {
+			continue
+		}
+
+		for _, violation := range documented.Schema.Validate(headerValue(strings.Join(values, ","), documented.Schema)) {
+			messages = append(messages, fmt.Sprintf("header %s %s", name, violation.String()))
+		}
+	}
+
+	return messages
+}
+
+// CompareHeaders checks the returned headers against the expected ones.
+func (r AliResponse) CompareHeaders(header http.Header) []string {
+	var messages []string
+
+	for _, name := range sortedKeys(r.Headers) {
+		expected := r.Headers[name]
+		values, present := header[http.CanonicalHeaderKey(name)]
+		if !present {
+			messages = append(messages, fmt.Sprintf("header %s is expected but missing", name))
+			continue
+		}
+
+		actual := strings.Join(values, ",")
+		if expected.Pattern == "" {
+			if actual != expected.Value {
+				messages = append(messages, fmt.Sprintf("header %s is %q, expect %q", name, actual, expected.Value))
+			}
+			continue
+		}
+
+		pattern, err := expected.compilePattern()
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("header %s has an %v", name, err))
+		} else if !pattern.MatchString(actual) {
+			messages = append(messages, fmt.Sprintf("header %s is %q, expect to match %s", name, actual, expected.Pattern))
+		}
+	}
+
+	return messages
+}
+
+// headerValue converts a raw header value to the json value described by the schema,
+// using the simple style of open api headers.
+func headerValue(raw string, schema *Schema) interface{} {
+	for schema != nil && schema.resolved != nil {
+		schema = schema.resolved
+	}
+
+	if schema == nil {
+		return raw
+	}
+
+	switch {
+	case schema.Type.contains("array"):
+		items := []interface{}{}
+		for _, item := range strings.Split(raw, ",") {
+			items = append(items, headerValue(strings.TrimSpace(item), schema.Items))
+		}
+		return items
+	case schema.Type.contains("integer"), schema.Type.contains("number"):
+		if number, err := strconv.ParseFloat(raw, 64); err == nil {
+			return number
+		}
+	case schema.Type.contains("boolean"):
+		if boolean, err := strconv.ParseBool(raw); err == nil {
+			return boolean
+		}
+	}
+	return raw
+}
+
+func sortedKeys[T any](values map[string]T) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,166 @@
+package alitest_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/toolzup/alitest"
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidateHeaders(t *testing.T) {
+	var response alitest.OpenApiResponse
+
+	err := yaml.Unmarshal([]byte(`
+headers:
+  Location:
+    required: true
+    schema:
+      type: string
+  ETag:
+    required: true
+  X-Rate-Limit-Remaining:
+    schema:
+      type: integer
+      minimum: 0
+  X-Rate-Limit-Reset:
+    schema:
+      type: integer
+  X-Allowed-Sizes:
+    schema:
+      type: array
+      items:
+        type: integer
+  Content-Type:
+    required: true
+`), &response)
+
+	if err != nil {
+		t.Fatalf("expect nil error, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		messages []string
+	}{
+		{
+			name: "valid headers",
+			header: http.Header{
+				"Location":               {"/pet/5"},
+				"Etag":                   {`"33a64df5"`},
+				"X-Rate-Limit-Remaining": {"99"},
+				"X-Allowed-Sizes":        {"1, 2, 3"},
+			},
+		},
+		{
+			name: "missing required headers",
+			header: http.Header{
+				"X-Rate-Limit-Remaining": {"99"},
+			},
+			messages: []string{"header ETag is required but missing", "header Location is required but missing"},
+		},
+		{
+			name: "invalid header values",
+			header: http.Header{
+				"Location":               {"/pet/5"},
+				"Etag":                   {`"33a64df5"`},
+				"X-Rate-Limit-Remaining": {"-1"},
+				"X-Rate-Limit-Reset":     {"tomorrow"},
+				"X-Allowed-Sizes":        {"1,big"},
+			},
+			messages: []string{
+				"header X-Allowed-Sizes #/1 (type): expect integer, got string",
+				"header X-Rate-Limit-Remaining # (minimum): -1 is lower than 0",
+				"header X-Rate-Limit-Reset # (type): expect integer, got string",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messages := response.ValidateHeaders(test.header)
+
+			if strings.Join(messages, "\n") != strings.Join(test.messages, "\n") {
+				t.Fatalf("expect %v, got %v", test.messages, messages)
+			}
+		})
+	}
+}
+
+func TestCompareHeaders(t *testing.T) {
+	response := alitest.AliResponse{
+		Headers: map[string]alitest.AliHeader{
+			"Location":      {Pattern: "^/pet/[0-9]+$"},
+			"Cache-Control": {Value: "no-store"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		messages []string
+	}{
+		{
+			name: "expected headers",
+			header: http.Header{
+				"Location":      {"/pet/5"},
+				"Cache-Control": {"no-store"},
+			},
+		},
+		{
+			name: "unexpected values",
+			header: http.Header{
+				"Location":      {"/pet/medor"},
+				"Cache-Control": {"max-age=60"},
+			},
+			messages: []string{
+				`header Cache-Control is "max-age=60", expect "no-store"`,
+				`header Location is "/pet/medor", expect to match ^/pet/[0-9]+$`,
+			},
+		},
+		{
+			name:   "missing headers",
+			header: http.Header{},
+			messages: []string{
+				"header Cache-Control is expected but missing",
+				"header Location is expected but missing",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messages := response.CompareHeaders(test.header)
+
+			if strings.Join(messages, "\n") != strings.Join(test.messages, "\n") {
+				t.Fatalf("expect %v, got %v", test.messages, messages)
+			}
+		})
+	}
+}
+
+func TestParseInvalidHeaderPattern(t *testing.T) {
+	spec := `
+info:
+  title: invalid header pattern
+paths:
+  /pet:
+    get:
+      responses:
+        200:
+          x-ali-cases:
+            - name: located pet
+              response:
+                headers:
+                  Location:
+                    pattern: '^/pet/[0-9+$'
+`
+
+	_, err := alitest.ParseString(spec)
+
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid x-ali-response of GET /pet (200) : header Location has an invalid pattern ^/pet/[0-9+$ : ") {
+		t.Fatalf("expect invalid header pattern error, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package alitest
+
+import (
+	"context"
+	"testing"
+)
+
+type (
+	// SuiteHook is called once before, or after, all the tests of a suite run.
+	SuiteHook func(ctx context.Context) error
+
+	// OperationHook is called before, or after, each request of an operation test.
+	OperationHook func(ctx context.Context, parameters OperationHookParameters) error
+
+	// OperationHookParameters describes the request of the operation test being run.
+	OperationHookParameters struct {
+		OperationID string
+		StatusCode  string
+		// Parameters are the resolved x-ali-parameters of the request
+		Parameters map[string]AliParameter
+		// Body is the resolved x-ali-body of the request
+		Body interface{}
+	}
+)
+
+// runSuiteHooks calls BeforeSuite, and registers AfterSuite to be called when the suite test ends.
+// Hook failures are reported as setup errors, not as contract failures.
+func (r *suiteRun) runSuiteHooks(t *testing.T) {
+	t.Cleanup(func() {
+		if r.parameters.AfterSuite == nil {
+			return
+		}
+		if err := r.parameters.AfterSuite(r.ctx); err != nil {
+			t.Errorf("Teardown error: the AfterSuite hook failed (%v)", err)
+		}
+	})
+
+	if r.parameters.BeforeSuite == nil {
+		return
+	}
+	if err := r.parameters.BeforeSuite(r.ctx); err != nil {
+		t.Fatalf("Setup error: the BeforeSuite hook failed (%v)", err)
+	}
+}
+
+// runOperationHooks calls the BeforeOperation hook of the operation, and registers its
+// AfterOperation hook to be called when the test ends.
+// Hook failures are reported as setup errors, not as contract failures.
+func (r *suiteRun) runOperationHooks(t *testing.T, parameters OperationHookParameters) {
+	if after, found := r.parameters.AfterOperation[parameters.OperationID]; found {
+		t.Cleanup(func() {
+			if err := after(r.ctx, parameters); err != nil {
+				t.Errorf("Teardown error: the AfterOperation hook of %s (%s) failed (%v)", parameters.OperationID, parameters.StatusCode, err)
+			}
+		})
+	}
+
+	before, found := r.parameters.BeforeOperation[parameters.OperationID]
+	if !found {
+		return
+	}
+	if err := before(r.ctx, parameters); err != nil {
+		t.Fatalf("Setup error: the BeforeOperation hook of %s (%s) failed (%v)", parameters.OperationID, parameters.StatusCode, err)
+	}
+}
@@ -0,0 +1,271 @@
+package alitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Matchers of the expected payloads. A matcher is an object with a single matcher key,
+// e.g. {"$match": "uuid"}, and is checked against the returned value at the same place.
+// A key starting with "$$" is escaped and expects the key without its first "$", e.g.
+// {"$$type": "Dog"} expects the literal {"$type": "Dog"}.
+const (
+	// MatchMatcher checks a named format: uuid, email, uri, date, date-time, ipv4 or ipv6
+	MatchMatcher = "$match"
+	// RegexMatcher checks a string against a regular expression
+	RegexMatcher = "$regex"
+	// TypeMatcher checks the json schema type, or one of a list of types
+	TypeMatcher = "$type"
+	// RangeMatcher checks a number is within [min, max], a null bound is unbounded
+	RangeMatcher = "$range"
+	// DatetimeMatcher checks a string is a date with the layout: rfc3339, rfc1123, date, or a go time layout
+	DatetimeMatcher = "$datetime"
+	// AnyMatcher accepts any value, as long as it is present
+	AnyMatcher = "$any"
+)
+
+var (
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+	datetimeLayouts = map[string]string{
+		"rfc3339": time.RFC3339,
+		"rfc1123": time.RFC1123,
+		"date":    time.DateOnly,
+	}
+)
+
+// matcher returns the name and argument of a matcher object.
+func matcher(value interface{}) (string, interface{}, bool) {
+	object, isObject := value.(map[string]interface{})
+	if !isObject || len(object) != 1 {
+		return "", nil, false
+	}
+	for name, argument := range object {
+		switch name {
+		case MatchMatcher, RegexMatcher, TypeMatcher, RangeMatcher, DatetimeMatcher, AnyMatcher:
+			return name, argument, true
+		}
+	}
+	return "", nil, false
+}
+
+// escapedKeyPrefix starts the expected keys written literally, without their first "$".
+const escapedKeyPrefix = "$$"
+
+// unescapeKey returns the returned payload key an expected payload key stands for.
+func unescapeKey(key string) string {
+	if strings.HasPrefix(key, escapedKeyPrefix) {
+		return key[1:]
+	}
+	return key
+}
+
+// containsMatcher tells if an expected payload uses any matcher, or any escaped key.
+func containsMatcher(value interface{}) bool {
+	if _, _, isMatcher := matcher(value); isMatcher {
+		return true
+	}
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for key, child := range typedValue {
+			if strings.HasPrefix(key, escapedKeyPrefix) || containsMatcher(child) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, child := range typedValue {
+			if containsMatcher(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyMatchers replaces the matchers of the expected payload by the actual values they accept,
+// so that the remaining comparison ignores them, and unescapes the keys of the expected payload.
+// The failed matchers are appended to mismatches.
+func applyMatchers(expected, actual interface{}, pointer string, mismatches *[]string) interface{} {
+	if name, argument, isMatcher := matcher(expected); isMatcher {
+		if message := checkMatcher(name, argument, actual); message != "" {
+			*mismatches = append(*mismatches, fmt.Sprintf("#%s (%s): %s", pointer, name, message))
+			return expected
+		}
+		return actual
+	}
+
+	switch typedExpected := expected.(type) {
+	case map[string]interface{}:
+		typedActual, isObject := actual.(map[string]interface{})
+		if !isObject {
+			return expected
+		}
+		applied := make(map[string]interface{}, len(typedExpected))
+		for escapedKey, child := range typedExpected {
+			key := unescapeKey(escapedKey)
+			actualChild, present := typedActual[key]
+			if name, _, isMatcher := matcher(child); isMatcher && !present {
+				*mismatches = append(*mismatches, fmt.Sprintf("#%s/%s (%s): value is missing", pointer, escapeJSONPointer(key), name))
+				applied[key] = child
+				continue
+			}
+			applied[key] = applyMatchers(child, actualChild, pointer+"/"+escapeJSONPointer(key), mismatches)
+		}
+		return applied
+	case []interface{}:
+		typedActual, isArray := actual.([]interface{})
+		if !isArray {
+			return expected
+		}
+		applied := make([]interface{}, len(typedExpected))
+		for index, child := range typedExpected {
+			if index >= len(typedActual) {
+				applied[index] = child
+				continue
+			}
+			applied[index] = applyMatchers(child, typedActual[index], pointer+"/"+strconv.Itoa(index), mismatches)
+		}
+		return applied
+	default:
+		return expected
+	}
+}
+
+// checkMatcher returns why the value is not accepted by the matcher, or an empty string.
+func checkMatcher(name string, argument, value interface{}) string {
+	switch name {
+	case AnyMatcher:
+		return ""
+	case TypeMatcher:
+		var types SchemaTypes
+		switch typedArgument := argument.(type) {
+		case string:
+			types = SchemaTypes{typedArgument}
+		case []interface{}:
+			for _, schemaType := range typedArgument {
+				types = append(types, fmt.Sprint(schemaType))
+			}
+		default:
+			return fmt.Sprintf("invalid argument %v, expect a type or a list of types", argument)
+		}
+		if !types.accepts(value) {
+			return fmt.Sprintf("expect %s, got %s", types, jsonType(value))
+		}
+		return ""
+	case RangeMatcher:
+		bounds, isArray := argument.([]interface{})
+		if !isArray || len(bounds) != 2 {
+			return fmt.Sprintf("invalid argument %v, expect [min, max]", argument)
+		}
+		number, isNumber := value.(float64)
+		if !isNumber {
+			return fmt.Sprintf("expect a number, got %s", jsonType(value))
+		}
+		for index, bound := range bounds {
+			limit, isNumber := bound.(float64)
+			if bound != nil && !isNumber {
+				return fmt.Sprintf("invalid argument %v, expect [min, max]", argument)
+			}
+			if bound != nil && ((index == 0 && number < limit) || (index == 1 && number > limit)) {
+				return fmt.Sprintf("%v is not within %s", number, rangeString(bounds))
+			}
+		}
+		return ""
+	}
+
+	// the other matchers check strings
+	pattern, isString := argument.(string)
+	if !isString {
+		return fmt.Sprintf("invalid argument %v, expect a string", argument)
+	}
+	text, isString := value.(string)
+	if !isString {
+		return fmt.Sprintf("expect a string, got %s", jsonType(value))
+	}
+
+	switch name {
+	case RegexMatcher:
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Sprintf("invalid regular expression %s : %v", pattern, err)
+		}
+		if !compiled.MatchString(text) {
+			return fmt.Sprintf("%q does not match %s", text, pattern)
+		}
+	case DatetimeMatcher:
+		layout, found := datetimeLayouts[strings.ToLower(pattern)]
+		if !found {
+			layout = pattern
+		}
+		if _, err := time.Parse(layout, text); err != nil {
+			return fmt.Sprintf("%q is not a %s date", text, pattern)
+		}
+	case MatchMatcher:
+		matches, known := matchFormat(pattern, text)
+		if !known {
+			return fmt.Sprintf("unknown format %s", pattern)
+		}
+		if !matches {
+			return fmt.Sprintf("%q is not a %s", text, pattern)
+		}
+	}
+	return ""
+}
+
+// matchFormat checks a string against a named format, it returns false for an unknown format.
+func matchFormat(format, text string) (bool, bool) {
+	switch format {
+	case "uuid":
+		return uuidPattern.MatchString(text), true
+	case "email":
+		address, err := mail.ParseAddress(text)
+		return err == nil && address.Address == text, true
+	case "uri":
+		parsed, err := url.Parse(text)
+		return err == nil && parsed.IsAbs(), true
+	case "date":
+		_, err := time.Parse(time.DateOnly, text)
+		return err == nil, true
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, text)
+		return err == nil, true
+	case "ipv4":
+		ip := net.ParseIP(text)
+		return ip != nil && ip.To4() != nil && !strings.Contains(text, ":"), true
+	case "ipv6":
+		ip := net.ParseIP(text)
+		return ip != nil && strings.Contains(text, ":"), true
+	}
+	return false, false
+}
+
+func rangeString(bounds []interface{}) string {
+	encoded, err := json.Marshal(bounds)
+	if err != nil {
+		return fmt.Sprint(bounds)
+	}
+	return string(encoded)
+}
+
+// matchPayloads applies the matchers of the expected payload to the actual one.
+// It returns the expected payload with the accepted values, and the failed matchers.
+func matchPayloads(actualPayload, expectedPayload []byte) ([]byte, []string, error) {
+	var actual, expected interface{}
+	if err := json.Unmarshal(actualPayload, &actual); err != nil {
+		return nil, nil, fmt.Errorf("cannot decode the returned payload : %w", err)
+	}
+	if err := json.Unmarshal(expectedPayload, &expected); err != nil {
+		return nil, nil, fmt.Errorf("cannot decode the expected payload : %w", err)
+	}
+
+	var mismatches []string
+	expectedPayload, err := json.Marshal(applyMatchers(expected, actual, "", &mismatches))
+	return expectedPayload, mismatches, err
+}
@@ -0,0 +1,152 @@
+package alitest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toolzup/alitest"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCompareMatchers(t *testing.T) {
+	testCases := []struct {
+		description  string
+		expected     string
+		bodyResponse string
+		identical    bool
+		details      string
+	}{
+		{
+			description:  "uuid",
+			expected:     "id: {$match: uuid}\nname: Medor",
+			bodyResponse: `{"id": "0b8f6a4e-3a4c-4b8e-9a4f-2d1c3e5f6a7b", "name": "Medor"}`,
+			identical:    true,
+		},
+		{
+			description:  "not a uuid",
+			expected:     "id: {$match: uuid}\nname: Medor",
+			bodyResponse: `{"id": "medor", "name": "Medor"}`,
+			details:      `#/id ($match): "medor" is not a uuid`,
+		},
+		{
+			description:  "email and uri",
+			expected:     "owner: {$match: email}\nsite: {$match: uri}",
+			bodyResponse: `{"owner": "jane@example.com", "site": "https://example.com/pets"}`,
+			identical:    true,
+		},
+		{
+			description:  "regex",
+			expected:     "token: {$regex: '^[a-f0-9]{8}$'}",
+			bodyResponse: `{"token": "deadbeef"}`,
+			identical:    true,
+		},
+		{
+			description:  "regex mismatch in an array",
+			expected:     "- name: {$regex: '^M'}\n- name: {$regex: '^M'}",
+			bodyResponse: `[{"name": "Medor"}, {"name": "Rex"}]`,
+			details:      `#/1/name ($regex): "Rex" does not match ^M`,
+		},
+		{
+			description:  "type",
+			expected:     "id: {$type: integer}\ntag: {$type: [string, 'null']}",
+			bodyResponse: `{"id": 5, "tag": null}`,
+			identical:    true,
+		},
+		{
+			description:  "type mismatch",
+			expected:     "id: {$type: integer}",
+			bodyResponse: `{"id": 5.5}`,
+			details:      "#/id ($type): expect integer, got number",
+		},
+		{
+			description:  "range",
+			expected:     "age: {$range: [1, 10]}\nweight: {$range: [0, null]}",
+			bodyResponse: `{"age": 10, "weight": 1234}`,
+			identical:    true,
+		},
+		{
+			description:  "out of range",
+			expected:     "age: {$range: [1, 10]}",
+			bodyResponse: `{"age": 11}`,
+			details:      "#/age ($range): 11 is not within [1,10]",
+		},
+		{
+			description:  "datetime",
+			expected:     "createdAt: {$datetime: rfc3339}\nbirthday: {$datetime: date}",
+			bodyResponse: `{"createdAt": "2024-03-01T10:00:00Z", "birthday": "2020-02-29"}`,
+			identical:    true,
+		},
+		{
+			description:  "not a datetime",
+			expected:     "createdAt: {$datetime: rfc3339}",
+			bodyResponse: `{"createdAt": "yesterday"}`,
+			details:      `#/createdAt ($datetime): "yesterday" is not a rfc3339 date`,
+		},
+		{
+			description:  "any",
+			expected:     "id: {$any: true}\nname: Medor",
+			bodyResponse: `{"id": {"value": 5}, "name": "Medor"}`,
+			identical:    true,
+		},
+		{
+			description:  "missing value",
+			expected:     "id: {$any: true}\nname: Medor",
+			bodyResponse: `{"name": "Medor"}`,
+			details:      "#/id ($any): value is missing",
+		},
+		{
+			description:  "matcher and other difference",
+			expected:     "id: {$match: uuid}\nname: Medor",
+			bodyResponse: `{"id": "0b8f6a4e-3a4c-4b8e-9a4f-2d1c3e5f6a7b", "name": "Rex"}`,
+		},
+		{
+			description:  "unknown format",
+			expected:     "id: {$match: isbn}",
+			bodyResponse: `{"id": "978-3-16-148410-0"}`,
+			details:      "#/id ($match): unknown format isbn",
+		},
+		{
+			description:  "not a matcher",
+			expected:     "query: {$regex: '^M', $options: i}",
+			bodyResponse: `{"query": {"$regex": "^M", "$options": "i"}}`,
+			identical:    true,
+		},
+		{
+			description:  "escaped matcher key",
+			expected:     "pet: {$$type: Dog}",
+			bodyResponse: `{"pet": {"$type": "Dog"}}`,
+			identical:    true,
+		},
+		{
+			description:  "escaped matcher key mismatch",
+			expected:     "pet: {$$type: Dog}",
+			bodyResponse: `{"pet": {"$type": "Cat"}}`,
+		},
+		{
+			description:  "escaped dollar key",
+			expected:     "$$$type: Dog",
+			bodyResponse: `{"$$type": "Dog"}`,
+			identical:    true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.description, func(t *testing.T) {
+			var response alitest.AliResponse
+
+			if err := yaml.Unmarshal([]byte(testCase.expected), &response.Expected); err != nil {
+				t.Fatalf("expect nil error, got %v", err)
+			}
+
+			identical, details := response.Compare([]byte(testCase.bodyResponse))
+
+			if identical != testCase.identical {
+				t.Fatalf("expect identical to be %t, got %t. Details : %s", testCase.identical, identical, details)
+			}
+
+			if !strings.Contains(details, testCase.details) {
+				t.Fatalf("expect details to contain %q, got %s", testCase.details, details)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package alitest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// scheduledOperation is an operation of the document, with the path declaring it.
+type scheduledOperation struct {
+	path        string
+	pathServers []OpenApiServer
+	pathOperation
+}
+
+// scheduleOperations returns the operations in execution order.
+// The default order is by path, then verb; x-ali-dependsOn moves an operation after its prerequisites.
+func (d OpenApiDocument) scheduleOperations() ([]scheduledOperation, error) {
+	paths := make([]string, 0, len(d.Paths))
+	for path := range d.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var operations []scheduledOperation
+	byID := map[string]int{}
+	for _, path := range paths {
+		for _, op := range d.Paths[path].operations() {
+			if operationID := op.operation.OperationID; operationID != "" {
+				if previous, duplicate := byID[operationID]; duplicate {
+					return nil, fmt.Errorf("duplicate operationId %s of %s %s and %s %s", operationID, operations[previous].verb, operations[previous].path, op.verb, path)
+				}
+				byID[operationID] = len(operations)
+			}
+			operations = append(operations, scheduledOperation{path: path, pathServers: d.Paths[path].Servers, pathOperation: op})
+		}
+	}
+
+	// dependents lists, for each operation, the operations depending on it
+	dependents := make([][]int, len(operations))
+	prerequisites := make([]int, len(operations))
+	for index, op := range operations {
+		for _, dependency := range op.operation.AliDependsOn {
+			operationID, statusCode, err := parseDependency(dependency)
+			if err != nil {
+				return nil, fmt.Errorf("invalid x-ali-dependsOn of %s %s : %w", op.verb, op.path, err)
+			}
+			prerequisite, found := byID[operationID]
+			if !found {
+				return nil, fmt.Errorf("invalid x-ali-dependsOn of %s %s : unknown operation %s", op.verb, op.path, operationID)
+			}
+			if _, found := operations[prerequisite].operation.Responses[statusCode]; !found {
+				return nil, fmt.Errorf("invalid x-ali-dependsOn of %s %s : %s has no %s response", op.verb, op.path, operationID, statusCode)
+			}
+			dependents[prerequisite] = append(dependents[prerequisite], index)
+			prerequisites[index]++
+		}
+	}
+
+	// topological sort, always picking the first ready operation in the default order
+	scheduled := make([]scheduledOperation, 0, len(operations))
+	done := make([]bool, len(operations))
+	for len(scheduled) < len(operations) {
+		next := -1
+		for index := range operations {
+			if !done[index] && prerequisites[index] == 0 {
+				next = index
+				break
+			}
+		}
+
+		if next < 0 {
+			return nil, fmt.Errorf("circular x-ali-dependsOn between the operations %s", strings.Join(cycleOperations(operations, dependents, done), ", "))
+		}
+
+		done[next] = true
+		scheduled = append(scheduled, operations[next])
+		for _, dependent := range dependents[next] {
+			prerequisites[dependent]--
+		}
+	}
+
+	return scheduled, nil
+}
+
+// parseDependency splits an operationId/status dependency.
+func parseDependency(dependency string) (string, string, error) {
+	separator := strings.LastIndex(dependency, "/")
+	if separator <= 0 || separator == len(dependency)-1 {
+		return "", "", fmt.Errorf("%q is not an operationId/status", dependency)
+	}
+
+	operationID, statusCode := dependency[:separator], dependency[separator+1:]
+	if statusCode != DefaultResponse {
+		statusCode = strings.ToUpper(statusCode)
+	}
+	return operationID, statusCode, nil
+}
+
+// unmetDependency returns the first prerequisite of the operation which did not pass.
+func (r *suiteRun) unmetDependency(operation OpenApiOperation) (string, CoverageStatus, bool) {
+	for _, dependency := range operation.AliDependsOn {
+		operationID, statusCode, err := parseDependency(dependency)
+		if err != nil {
+			return dependency, NotExercised, true
+		}
+
+		status := NotExercised
+		for _, entry := range r.coverage {
+			if entry.OperationID == operationID && entry.StatusCode == statusCode {
+				status = entry.Status
+				break
+			}
+		}
+
+		if status != Passed && status != NoTestData {
+			return dependency, status, true
+		}
+	}
+	return "", "", false
+}
+
+// cycleOperations lists the operations left unscheduled because of a cycle.
+// The operations only waiting for the cycle, without leading back to it, are not listed.
+func cycleOperations(operations []scheduledOperation, dependents [][]int, done []bool) []string {
+	inCycle := make([]bool, len(operations))
+	for index := range operations {
+		inCycle[index] = !done[index]
+	}
+
+	for pruned := true; pruned; {
+		pruned = false
+		for index := range operations {
+			if !inCycle[index] {
+				continue
+			}
+			leadsBack := false
+			for _, dependent := range dependents[index] {
+				leadsBack = leadsBack || inCycle[dependent]
+			}
+			if !leadsBack {
+				inCycle[index] = false
+				pruned = true
+			}
+		}
+	}
+
+	var names []string
+	for index, op := range operations {
+		if inCycle[index] {
+			names = append(names, op.operation.OperationID)
+		}
+	}
+	return names
+}
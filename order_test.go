@@ -0,0 +1,123 @@
+package alitest_test
+
+import (
+	_ "embed"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/toolzup/alitest"
+)
+
+//go:embed dataset/order_specification.yaml
+var orderSpec string
+
+func TestRunDependsOn(t *testing.T) {
+	var calls []string
+
+	integrationSuite, err := alitest.ParseString(orderSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			if _, err := w.Write([]byte(`{"id": 5}`)); err != nil {
+				t.Errorf("expect nil error, but got %v", err)
+			}
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	integrationSuite.Run(t, alitest.RunParameters{URL: srv.URL})
+
+	expected := []string{"POST /pets", "GET /pets", "GET /pets/5", "DELETE /pets/5", "GET /owners"}
+
+	if strings.Join(calls, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expect calls %v, but got %v", expected, calls)
+	}
+}
+
+func TestRunDependsOnSkipped(t *testing.T) {
+	var calls []string
+
+	integrationSuite, err := alitest.ParseString(strings.Replace(orderSpec, "          description: created\n", "          description: created\n          x-ali-skip: not implemented yet\n", 1))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+	}))
+	t.Cleanup(srv.Close)
+
+	report := integrationSuite.Run(t, alitest.RunParameters{URL: srv.URL})
+
+	if len(calls) != 0 {
+		t.Fatalf("expect no call, but got %v", calls)
+	}
+
+	if report.Totals.Skipped != report.Totals.Total {
+		t.Fatalf("expect all the tests to be skipped, but got %+v", report.Totals)
+	}
+}
+
+func TestParseDependsOn(t *testing.T) {
+	tests := []struct {
+		name      string
+		dependsOn string
+		err       string
+	}{
+		{
+			name:      "unknown operation",
+			dependsOn: "[addOwner/201]",
+			err:       "invalid x-ali-dependsOn of GET /pets : unknown operation addOwner",
+		},
+		{
+			name:      "undocumented status",
+			dependsOn: "[addPet/200]",
+			err:       "invalid x-ali-dependsOn of GET /pets : addPet has no 200 response",
+		},
+		{
+			name:      "missing status",
+			dependsOn: "[addPet]",
+			err:       `invalid x-ali-dependsOn of GET /pets : "addPet" is not an operationId/status`,
+		},
+		{
+			name:      "cycle",
+			dependsOn: "[deletePet/204]",
+			err:       "circular x-ali-dependsOn between the operations listPets, getPet, deletePet",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := strings.Replace(orderSpec, "x-ali-dependsOn: [addPet/201]\n", "x-ali-dependsOn: "+test.dependsOn+"\n", 1)
+
+			_, err := alitest.ParseString(spec)
+
+			if err == nil || err.Error() != test.err {
+				t.Fatalf("expect error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestParseDuplicateOperationID(t *testing.T) {
+	spec := strings.Replace(orderSpec, "operationId: getPet\n", "operationId: listPets\n", 1)
+
+	_, err := alitest.ParseString(spec)
+
+	expected := "duplicate operationId listPets of GET /pets and GET /pets/{petId}"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expect error %q, got %v", expected, err)
+	}
+}
@@ -0,0 +1,246 @@
+package alitest
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Serialization styles of the parameters.
+const (
+	// SimpleStyle is the default of the path and header parameters: a,b or k1,v1 or k1=v1 exploded
+	SimpleStyle = "simple"
+	// LabelStyle prefixes the path value with a dot: .a,b or .a.b exploded
+	LabelStyle = "label"
+	// MatrixStyle is a path value with the name: ;id=a,b or ;id=a;id=b exploded
+	MatrixStyle = "matrix"
+	// FormStyle is the default of the query and cookie parameters: id=a,b or id=a&id=b exploded
+	FormStyle = "form"
+	// SpaceDelimitedStyle joins the array items with a space: id=a%20b
+	SpaceDelimitedStyle = "spaceDelimited"
+	// PipeDelimitedStyle joins the array items with a pipe: id=a|b
+	PipeDelimitedStyle = "pipeDelimited"
+	// DeepObjectStyle sends each property of an object: id[k1]=v1&id[k2]=v2
+	DeepObjectStyle = "deepObject"
+)
+
+// locationStyles lists the styles allowed at each location, the first one is the default.
+var locationStyles = map[ParameterLocation][]string{
+	Path:   {SimpleStyle, LabelStyle, MatrixStyle},
+	Query:  {FormStyle, SpaceDelimitedStyle, PipeDelimitedStyle, DeepObjectStyle},
+	Header: {SimpleStyle},
+	Cookie: {FormStyle},
+}
+
+// reservedCharacters are left unescaped in the query values of the parameters allowing them.
+// "#" and "+" are reserved too but always escaped: "#" would start the url fragment, and a
+// "+" would read as a space.
+const reservedCharacters = ":/?[]@!$&'()*,;="
+
+// parameterShape is the kind of value of a parameter.
+type parameterShape int
+
+const (
+	primitiveShape parameterShape = iota
+	arrayShape
+	objectShape
+)
+
+// parameterValue is a parameter value split into its items, or its properties sorted by name.
+type parameterValue struct {
+	shape  parameterShape
+	keys   []string
+	values []string
+}
+
+func newParameterValue(value any) parameterValue {
+	switch value.(type) {
+	case nil, string, bool, int, int64, float64:
+		return parameterValue{shape: primitiveShape, values: []string{formatParameter(value)}}
+	}
+
+	switch typedValue := normalizeJSON(value).(type) {
+	case []interface{}:
+		parameter := parameterValue{shape: arrayShape}
+		for _, item := range typedValue {
+			parameter.values = append(parameter.values, formatParameter(item))
+		}
+		return parameter
+	case map[string]interface{}:
+		parameter := parameterValue{shape: objectShape}
+		for _, key := range sortedKeys(typedValue) {
+			parameter.keys = append(parameter.keys, key)
+			parameter.values = append(parameter.values, formatParameter(typedValue[key]))
+		}
+		return parameter
+	}
+	return parameterValue{shape: primitiveShape, values: []string{formatParameter(value)}}
+}
+
+// formatParameter formats a primitive value, without exponent for the numbers.
+func formatParameter(value any) string {
+	switch typedValue := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(typedValue, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// escaped returns the value with its keys and items escaped.
+func (v parameterValue) escaped(escape func(string) string) parameterValue {
+	result := parameterValue{shape: v.shape}
+	for _, key := range v.keys {
+		result.keys = append(result.keys, escape(key))
+	}
+	for _, value := range v.values {
+		result.values = append(result.values, escape(value))
+	}
+	return result
+}
+
+// join joins the items of an array, or the keys and values of an object, with the separator.
+func (v parameterValue) join(separator string) string {
+	if v.shape != objectShape {
+		return strings.Join(v.values, separator)
+	}
+	parts := make([]string, 0, 2*len(v.keys))
+	for index, key := range v.keys {
+		parts = append(parts, key, v.values[index])
+	}
+	return strings.Join(parts, separator)
+}
+
+// pairs returns the items of an array prefixed by the name, or the key=value properties of an object.
+func (v parameterValue) pairs(name string) []string {
+	pairs := make([]string, 0, len(v.values))
+	for index, value := range v.values {
+		if v.shape == objectShape {
+			pairs = append(pairs, v.keys[index]+"="+value)
+		} else {
+			pairs = append(pairs, name+"="+value)
+		}
+	}
+	return pairs
+}
+
+// style returns the style of the parameter, or the default one of its location.
+func (p OpenApiParameter) style() string {
+	if p.Style != "" {
+		return p.Style
+	}
+	return locationStyles[p.In][0]
+}
+
+// explode tells if the items of the value are sent separately, the default of the form style.
+func (p OpenApiParameter) explode() bool {
+	if p.Explode != nil {
+		return *p.Explode
+	}
+	return p.style() == FormStyle
+}
+
+// validateStyle checks the style is allowed at the location of the parameter.
+func (p OpenApiParameter) validateStyle() error {
+	for _, style := range locationStyles[p.In] {
+		if p.style() == style {
+			return nil
+		}
+	}
+	return fmt.Errorf("style %s is not allowed for the %s parameter %s", p.style(), strings.ToLower(p.In.String()), p.Name)
+}
+
+// pathValue serializes the value of a path parameter, with each item escaped as a path segment.
+func (p OpenApiParameter) pathValue(value any) string {
+	parameter := newParameterValue(value).escaped(url.PathEscape)
+	name := url.PathEscape(p.Name)
+
+	switch p.style() {
+	case LabelStyle:
+		if p.explode() && parameter.shape == objectShape {
+			return "." + strings.Join(parameter.pairs(name), ".")
+		}
+		if p.explode() {
+			return "." + parameter.join(".")
+		}
+		return "." + parameter.join(",")
+	case MatrixStyle:
+		if p.explode() && parameter.shape != primitiveShape {
+			return ";" + strings.Join(parameter.pairs(name), ";")
+		}
+		return ";" + name + "=" + parameter.join(",")
+	}
+	return parameter.simple(p.explode())
+}
+
+// headerValue serializes the value of a header parameter, with the simple style.
+func (p OpenApiParameter) headerValue(value any) string {
+	return newParameterValue(value).simple(p.explode())
+}
+
+func (v parameterValue) simple(explode bool) string {
+	if explode && v.shape == objectShape {
+		return strings.Join(v.pairs(""), ",")
+	}
+	return v.join(",")
+}
+
+// queryPairs serializes the value of a query parameter into escaped name=value pairs.
+func (p OpenApiParameter) queryPairs(value any) []string {
+	escape := url.QueryEscape
+	if p.AllowReserved {
+		escape = escapeUnreserved
+	}
+	parameter := newParameterValue(value).escaped(escape)
+	name := escape(p.Name)
+
+	switch {
+	case p.style() == DeepObjectStyle && parameter.shape == objectShape:
+		pairs := make([]string, 0, len(parameter.keys))
+		for index, key := range parameter.keys {
+			pairs = append(pairs, fmt.Sprintf("%s[%s]=%s", name, key, parameter.values[index]))
+		}
+		return pairs
+	case parameter.shape == primitiveShape:
+		return []string{name + "=" + parameter.values[0]}
+	case p.explode():
+		return parameter.pairs(name)
+	case p.style() == SpaceDelimitedStyle:
+		return []string{name + "=" + parameter.join("%20")}
+	case p.style() == PipeDelimitedStyle:
+		return []string{name + "=" + parameter.join("|")}
+	}
+	return []string{name + "=" + parameter.join(",")}
+}
+
+// cookiePairs serializes the value of a cookie parameter into name=value pairs with the form style,
+// an exploded array or object is sent as several cookies. The pairs are not built with http.Cookie,
+// which quotes a value with a comma. Each item is percent-encoded, so that a ; a space or a quote
+// cannot break the Cookie header.
+func (p OpenApiParameter) cookiePairs(value any) []string {
+	parameter := newParameterValue(value).escaped(url.PathEscape)
+	if !p.explode() || parameter.shape == primitiveShape {
+		return []string{p.Name + "=" + parameter.join(",")}
+	}
+	if parameter.shape == objectShape {
+		return parameter.pairs("")
+	}
+	return parameter.pairs(p.Name)
+}
+
+// reservedUnescaper restores the reserved characters of a query escaped value.
+var reservedUnescaper = func() *strings.Replacer {
+	pairs := make([]string, 0, 2*len(reservedCharacters))
+	for _, char := range []byte(reservedCharacters) {
+		pairs = append(pairs, fmt.Sprintf("%%%02X", char), string(char))
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// escapeUnreserved escapes a query value, except the reserved characters. A space is escaped
+// as %20, so that it cannot be confused with a reserved "+".
+func escapeUnreserved(value string) string {
+	return reservedUnescaper.Replace(strings.ReplaceAll(url.QueryEscape(value), "+", "%20"))
+}
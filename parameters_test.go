@@ -0,0 +1,196 @@
+package alitest_test
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/toolzup/alitest"
+)
+
+//go:embed dataset/path_parameters_specification.yaml
+var pathParametersSpec string
+
+func TestRunPathParameters(t *testing.T) {
+	integrationSuite, err := alitest.ParseString(pathParametersSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.RequestURI)
+	})
+
+	report := integrationSuite.Run(t, alitest.RunParameters{Handler: handler})
+
+	expected := []string{"GET /pets/medor?verbose=true", "DELETE /pets/medor?reason=adopted"}
+	if strings.Join(calls, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expect calls %v, but got %v", expected, calls)
+	}
+
+	if report.Totals.Passed != 2 {
+		t.Fatalf("expect 2 passed responses, got %+v", report.Totals)
+	}
+}
+
+func TestParameterStyles(t *testing.T) {
+	explode, noExplode := true, false
+	array := []interface{}{"blue", "black", "brown"}
+	object := map[string]interface{}{"R": 100, "G": 200, "B": 150}
+
+	tests := []struct {
+		name      string
+		parameter alitest.OpenApiParameter
+		value     any
+		// paged adds a page query parameter after the tested one
+		paged    bool
+		expected string
+	}{
+		{name: "simple primitive", parameter: alitest.OpenApiParameter{In: alitest.Path}, value: "a b/c", expected: "/colors/a%20b%2Fc"},
+		{name: "simple array", parameter: alitest.OpenApiParameter{In: alitest.Path}, value: array, expected: "/colors/blue,black,brown"},
+		{name: "simple object", parameter: alitest.OpenApiParameter{In: alitest.Path}, value: object, expected: "/colors/B,150,G,200,R,100"},
+		{name: "simple exploded object", parameter: alitest.OpenApiParameter{In: alitest.Path, Explode: &explode}, value: object, expected: "/colors/B=150,G=200,R=100"},
+		{name: "label array", parameter: alitest.OpenApiParameter{In: alitest.Path, Style: "label"}, value: array, expected: "/colors/.blue,black,brown"},
+		{name: "label exploded array", parameter: alitest.OpenApiParameter{In: alitest.Path, Style: "label", Explode: &explode}, value: array, expected: "/colors/.blue.black.brown"},
+		{name: "label exploded object", parameter: alitest.OpenApiParameter{In: alitest.Path, Style: "label", Explode: &explode}, value: object, expected: "/colors/.B=150.G=200.R=100"},
+		{name: "matrix primitive", parameter: alitest.OpenApiParameter{In: alitest.Path, Style: "matrix"}, value: 5, expected: "/colors/;color=5"},
+		{name: "matrix array", parameter: alitest.OpenApiParameter{In: alitest.Path, Style: "matrix"}, value: array, expected: "/colors/;color=blue,black,brown"},
+		{name: "matrix exploded array", parameter: alitest.OpenApiParameter{In: alitest.Path, Style: "matrix", Explode: &explode}, value: array, expected: "/colors/;color=blue;color=black;color=brown"},
+		{name: "matrix exploded object", parameter: alitest.OpenApiParameter{In: alitest.Path, Style: "matrix", Explode: &explode}, value: object, expected: "/colors/;B=150;G=200;R=100"},
+		{name: "form primitive", parameter: alitest.OpenApiParameter{In: alitest.Query}, value: "a&b", expected: "/colors/{color}?color=a%26b"},
+		{name: "form number", parameter: alitest.OpenApiParameter{In: alitest.Query}, value: 12345678.0, expected: "/colors/{color}?color=12345678"},
+		{name: "form array", parameter: alitest.OpenApiParameter{In: alitest.Query}, value: array, expected: "/colors/{color}?color=blue&color=black&color=brown"},
+		{name: "form not exploded array", parameter: alitest.OpenApiParameter{In: alitest.Query, Explode: &noExplode}, value: array, expected: "/colors/{color}?color=blue,black,brown"},
+		{name: "form object", parameter: alitest.OpenApiParameter{In: alitest.Query}, value: object, expected: "/colors/{color}?B=150&G=200&R=100"},
+		{name: "form not exploded object", parameter: alitest.OpenApiParameter{In: alitest.Query, Explode: &noExplode}, value: object, expected: "/colors/{color}?color=B,150,G,200,R,100"},
+		{name: "space delimited array", parameter: alitest.OpenApiParameter{In: alitest.Query, Style: "spaceDelimited", Explode: &noExplode}, value: array, expected: "/colors/{color}?color=blue%20black%20brown"},
+		{name: "pipe delimited array", parameter: alitest.OpenApiParameter{In: alitest.Query, Style: "pipeDelimited", Explode: &noExplode}, value: array, expected: "/colors/{color}?color=blue|black|brown"},
+		{name: "deep object", parameter: alitest.OpenApiParameter{In: alitest.Query, Style: "deepObject", Explode: &explode}, value: object, expected: "/colors/{color}?color[B]=150&color[G]=200&color[R]=100"},
+		{name: "reserved characters", parameter: alitest.OpenApiParameter{In: alitest.Query, AllowReserved: true}, value: "/a?b=c d", expected: "/colors/{color}?color=/a?b=c%20d"},
+		{name: "reserved plus and space", parameter: alitest.OpenApiParameter{In: alitest.Query, AllowReserved: true}, value: "a b+c", expected: "/colors/{color}?color=a%20b%2Bc"},
+		{name: "reserved fragment character", parameter: alitest.OpenApiParameter{In: alitest.Query, AllowReserved: true}, value: "a#b", paged: true, expected: "/colors/{color}?color=a%23b&page=2"},
+		{name: "reserved multibyte characters", parameter: alitest.OpenApiParameter{In: alitest.Query, AllowReserved: true}, value: "é/à", expected: "/colors/{color}?color=%C3%A9/%C3%A0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.parameter.Name = "color"
+			response := alitest.OpenApiResponse{
+				AliParameters: map[string]alitest.AliParameter{"color": {Value: test.value}, "page": {Value: 2}},
+			}
+			params := []alitest.OpenApiParameter{test.parameter}
+			if test.paged {
+				params = append(params, alitest.OpenApiParameter{Name: "page", In: alitest.Query})
+			}
+
+			actualURL := response.ResolveURL("/colors/{color}", params)
+
+			if actualURL != test.expected {
+				t.Fatalf("expect %v, got %v", test.expected, actualURL)
+			}
+		})
+	}
+}
+
+func TestApplyParameterStyles(t *testing.T) {
+	explode, noExplode := true, false
+	response := alitest.OpenApiResponse{
+		AliParameters: map[string]alitest.AliParameter{
+			"X-Colors": {Value: []interface{}{"blue", "black"}},
+			"X-Point":  {Value: map[string]interface{}{"x": 1, "y": 2}},
+			"colors":   {Value: []interface{}{"blue", "black"}},
+			"point":    {Value: map[string]interface{}{"x": 1, "y": 2}},
+			"tones":    {Value: []interface{}{"light", "dark"}},
+			"hue":      {Value: map[string]interface{}{"h": 1, "s": 2}},
+		},
+	}
+	params := []alitest.OpenApiParameter{
+		{Name: "X-Colors", In: alitest.Header},
+		{Name: "X-Point", In: alitest.Header, Explode: &explode},
+		{Name: "colors", In: alitest.Cookie},
+		{Name: "point", In: alitest.Cookie},
+		{Name: "tones", In: alitest.Cookie, Explode: &noExplode},
+		{Name: "hue", In: alitest.Cookie, Explode: &noExplode},
+	}
+
+	request, err := http.NewRequest(http.MethodGet, "http://localhost/colors", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.ApplyParameters(request, params)
+
+	if actual := request.Header.Get("X-Colors"); actual != "blue,black" {
+		t.Fatalf("expect header X-Colors blue,black, got %s", actual)
+	}
+	if actual := request.Header.Get("X-Point"); actual != "x=1,y=2" {
+		t.Fatalf("expect header X-Point x=1,y=2, got %s", actual)
+	}
+	// the items of a not exploded cookie are not quoted, as http.Cookie would do for a comma
+	expectedCookie := "colors=blue; colors=black; x=1; y=2; tones=light,dark; hue=h,1,s,2"
+	if actual := request.Header.Get("Cookie"); actual != expectedCookie {
+		t.Fatalf("expect cookies %s, got %s", expectedCookie, actual)
+	}
+}
+
+func TestApplyCookieParameters(t *testing.T) {
+	explode, noExplode := true, false
+
+	tests := []struct {
+		name      string
+		parameter alitest.OpenApiParameter
+		value     any
+		expected  string
+	}{
+		{name: "primitive", parameter: alitest.OpenApiParameter{}, value: "some-session", expected: "session=some-session"},
+		{name: "exploded array", parameter: alitest.OpenApiParameter{}, value: []interface{}{"a", "b"}, expected: "session=a; session=b"},
+		{name: "not exploded array", parameter: alitest.OpenApiParameter{Explode: &noExplode}, value: []interface{}{"a,b", "c"}, expected: "session=a%2Cb,c"},
+		{name: "exploded object", parameter: alitest.OpenApiParameter{Explode: &explode}, value: map[string]interface{}{"a b": "c"}, expected: "a%20b=c"},
+		{name: "separator and space", parameter: alitest.OpenApiParameter{}, value: "a; admin=true b", expected: "session=a%3B%20admin=true%20b"},
+		{name: "quote", parameter: alitest.OpenApiParameter{}, value: `"a"`, expected: "session=%22a%22"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.parameter.Name = "session"
+			test.parameter.In = alitest.Cookie
+			response := alitest.OpenApiResponse{
+				AliParameters: map[string]alitest.AliParameter{"session": {Value: test.value}},
+			}
+
+			request, err := http.NewRequest(http.MethodGet, "http://localhost/pets", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.ApplyParameters(request, []alitest.OpenApiParameter{test.parameter})
+
+			if actual := request.Header.Get("Cookie"); actual != test.expected {
+				t.Fatalf("expect cookies %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseParameterStyle(t *testing.T) {
+	_, err := alitest.ParseString(`
+openapi: 3.0.1
+paths:
+  /colors:
+    get:
+      operationId: listColors
+      parameters:
+        - name: color
+          in: query
+          style: matrix
+      responses:
+        200:
+          description: successful operation
+`)
+
+	expected := "invalid parameter of GET /colors : style matrix is not allowed for the query parameter color"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expect error %q, got %v", expected, err)
+	}
+}
@@ -0,0 +1,114 @@
+package alitest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pointerWildcard matches every item of an array, or every property of an object.
+const pointerWildcard = "*"
+
+// parseJSONPointer splits a json pointer (RFC 6901) into its unescaped reference tokens.
+// The empty pointer targets the whole document.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid json pointer %q : must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, fmt.Errorf("invalid json pointer %q : bad escape sequence in %q", pointer, token)
+			}
+		}
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func escapeJSONPointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// removeJSONPointers removes the values targeted by the pointers from a decoded json value.
+// The pointerWildcard token targets every item of an array or property of an object.
+// The pointers are all evaluated against the original value, the array items are only
+// re-indexed once every targeted item is removed. The whole value is removed, as nil,
+// when a pointer is empty.
+func removeJSONPointers(value interface{}, pointers [][]string) interface{} {
+	for _, tokens := range pointers {
+		if len(tokens) == 0 {
+			return nil
+		}
+	}
+	if len(pointers) == 0 {
+		return value
+	}
+
+	// childPointers returns whether the child is removed, and the pointers to apply inside it
+	childPointers := func(key string) (bool, [][]string) {
+		var inside [][]string
+		for _, tokens := range pointers {
+			if tokens[0] != pointerWildcard && tokens[0] != key {
+				continue
+			}
+			if len(tokens) == 1 {
+				return true, nil
+			}
+			inside = append(inside, tokens[1:])
+		}
+		return false, inside
+	}
+
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for key, child := range typedValue {
+			removed, inside := childPointers(key)
+			if removed {
+				delete(typedValue, key)
+			} else {
+				typedValue[key] = removeJSONPointers(child, inside)
+			}
+		}
+		return typedValue
+	case []interface{}:
+		kept := make([]interface{}, 0, len(typedValue))
+		for index, child := range typedValue {
+			removed, inside := childPointers(strconv.Itoa(index))
+			if !removed {
+				kept = append(kept, removeJSONPointers(child, inside))
+			}
+		}
+		return kept
+	default:
+		return value
+	}
+}
+
+// lookupJSONPointer returns the value targeted by the tokens in a decoded json value.
+func lookupJSONPointer(value interface{}, tokens []string) (interface{}, bool) {
+	for _, token := range tokens {
+		switch typedValue := value.(type) {
+		case map[string]interface{}:
+			child, found := typedValue[token]
+			if !found {
+				return nil, false
+			}
+			value = child
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(typedValue) {
+				return nil, false
+			}
+			value = typedValue[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
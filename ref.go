@@ -0,0 +1,287 @@
+package alitest
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// refResolver inlines the $ref of a yaml document, loading the referenced files on demand.
+//
+// Schema references are kept as is: they are linked once the document is decoded, which
+// allows recursive schemas. The schemas of other files are collected in schemas.
+type refResolver struct {
+	rootFile  string
+	documents map[string]*yaml.Node
+	// resolving holds the references being inlined, to detect cycles
+	resolving map[string]bool
+	// schemas holds the schemas of other files, by their file qualified reference
+	schemas map[string]*yaml.Node
+}
+
+// nodeKind tells if a yaml node is a schema, whose references are linked instead of inlined.
+type nodeKind int
+
+const (
+	otherNode nodeKind = iota
+	schemaNode
+	// schemasNode holds schemas by name or by index, e.g. properties or allOf
+	schemasNode
+)
+
+// resolveRefs inlines the references of a root document read from file, and returns the
+// schemas referenced in other files, by the reference they are linked with.
+// An empty file name means the document does not come from a file,
+// relative references are then read from the working directory.
+func resolveRefs(root *yaml.Node, file string) (map[string]*yaml.Node, error) {
+	if file != "" {
+		absFile, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		file = absFile
+	}
+
+	resolver := refResolver{
+		rootFile:  file,
+		documents: map[string]*yaml.Node{file: root},
+		resolving: map[string]bool{},
+		schemas:   map[string]*yaml.Node{},
+	}
+	if err := resolver.resolve(root, file, otherNode); err != nil {
+		return nil, err
+	}
+	return resolver.schemas, nil
+}
+
+func (r *refResolver) resolve(node *yaml.Node, file string, kind nodeKind) error {
+	if node.Kind == yaml.MappingNode {
+		if ref, found := refValue(node); found {
+			if kind == schemaNode {
+				return r.link(node, file, ref)
+			}
+			return r.inline(node, file, ref, kind)
+		}
+	}
+
+	for i, child := range node.Content {
+		childKind := otherNode
+		switch {
+		case node.Kind == yaml.MappingNode && i%2 == 0:
+			// the keys hold no reference
+		case node.Kind == yaml.MappingNode && strings.HasPrefix(node.Content[i-1].Value, "x-"):
+			// the extensions hold test data, not specification nodes
+			continue
+		case node.Kind == yaml.MappingNode:
+			childKind = childNodeKind(kind, node.Content[i-1].Value)
+		case kind == schemasNode:
+			childKind = schemaNode
+		}
+		if err := r.resolve(child, file, childKind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// childNodeKind returns the kind of the value of a mapping node key.
+func childNodeKind(parent nodeKind, key string) nodeKind {
+	switch {
+	case parent == schemasNode:
+		return schemaNode
+	case parent == schemaNode && (key == "items" || key == "additionalProperties" || key == "not"):
+		return schemaNode
+	case parent == schemaNode && (key == "properties" || key == "allOf" || key == "oneOf" || key == "anyOf"):
+		return schemasNode
+	case parent == otherNode && key == "schema":
+		return schemaNode
+	case parent == otherNode && key == "schemas":
+		return schemasNode
+	}
+	return otherNode
+}
+
+// link keeps a schema reference, and collects the referenced schema when it is not a
+// component schema of the root document.
+func (r *refResolver) link(node *yaml.Node, file, ref string) error {
+	targetFile, fragment := r.target(file, ref)
+
+	if r.isComponentSchema(targetFile, fragment) {
+		// normalize to an internal reference, so it can be linked later
+		setRefValue(node, "#"+fragment)
+		return nil
+	}
+
+	key := targetFile + "#" + fragment
+	setRefValue(node, key)
+	if _, found := r.schemas[key]; found {
+		return nil
+	}
+
+	document, err := r.load(targetFile)
+	if err != nil {
+		return err
+	}
+
+	target, err := pointerLookup(document, fragment)
+	if err != nil {
+		return fmt.Errorf("cannot resolve reference %s : %w", ref, err)
+	}
+
+	// collected before its own references, a recursive schema links to itself
+	linked := copyNode(target)
+	r.schemas[key] = linked
+	return r.resolve(linked, targetFile, schemaNode)
+}
+
+// target returns the file and the json pointer of a reference.
+func (r *refResolver) target(file, ref string) (string, string) {
+	refFile, fragment, _ := strings.Cut(ref, "#")
+
+	targetFile := file
+	if refFile != "" {
+		targetFile = filepath.Join(filepath.Dir(file), refFile)
+	}
+	return targetFile, fragment
+}
+
+// isComponentSchema tells if a reference targets a component schema of the root document.
+func (r *refResolver) isComponentSchema(targetFile, fragment string) bool {
+	return targetFile == r.rootFile && strings.HasPrefix("#"+fragment, componentSchemaPrefix)
+}
+
+func (r *refResolver) inline(node *yaml.Node, file, ref string, kind nodeKind) error {
+	targetFile, fragment := r.target(file, ref)
+
+	if r.isComponentSchema(targetFile, fragment) {
+		setRefValue(node, "#"+fragment)
+		return nil
+	}
+
+	key := targetFile + "#" + fragment
+	if r.resolving[key] {
+		return fmt.Errorf("circular reference %s", ref)
+	}
+
+	document, err := r.load(targetFile)
+	if err != nil {
+		return err
+	}
+
+	target, err := pointerLookup(document, fragment)
+	if err != nil {
+		return fmt.Errorf("cannot resolve reference %s : %w", ref, err)
+	}
+
+	inlined := copyNode(target)
+
+	r.resolving[key] = true
+	err = r.resolve(inlined, targetFile, kind)
+	delete(r.resolving, key)
+
+	if err != nil {
+		return err
+	}
+
+	*node = *inlined
+	return nil
+}
+
+// load reads and caches the yaml document of a referenced file.
+func (r *refResolver) load(file string) (*yaml.Node, error) {
+	if document, found := r.documents[file]; found {
+		return document, nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("cannot read referenced file %s : %w", file, err)
+	}
+
+	r.documents[file] = &document
+	return &document, nil
+}
+
+func refValue(node *yaml.Node) (string, bool) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "$ref" {
+			return node.Content[i+1].Value, true
+		}
+	}
+	return "", false
+}
+
+func setRefValue(node *yaml.Node, ref string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "$ref" {
+			node.Content[i+1].Value = ref
+		}
+	}
+}
+
+// pointerLookup evaluates a json pointer against a yaml document.
+func pointerLookup(document *yaml.Node, pointer string) (*yaml.Node, error) {
+	node := document
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	if pointer == "" || pointer == "/" {
+		return node, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid json pointer %s", pointer)
+	}
+
+	for _, rawToken := range strings.Split(pointer[1:], "/") {
+		token, err := url.PathUnescape(rawToken)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+
+		next, err := childNode(node, token)
+		if err != nil {
+			return nil, err
+		}
+		node = next
+	}
+	return node, nil
+}
+
+func childNode(node *yaml.Node, token string) (*yaml.Node, error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == token {
+				return node.Content[i+1], nil
+			}
+		}
+	case yaml.SequenceNode:
+		index, err := strconv.Atoi(token)
+		if err == nil && index >= 0 && index < len(node.Content) {
+			return node.Content[index], nil
+		}
+	}
+	return nil, fmt.Errorf("%s not found", token)
+}
+
+func copyNode(node *yaml.Node) *yaml.Node {
+	copied := *node
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		copied.Content[i] = copyNode(child)
+	}
+	return &copied
+}
@@ -0,0 +1,153 @@
+package alitest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/toolzup/alitest"
+)
+
+func TestParseFileRefs(t *testing.T) {
+	var foundCalled bool
+	var notFoundCalled bool
+
+	integrationSuite, err := alitest.ParseFile("./dataset/refs/pet_api.yaml")
+
+	if err != nil {
+		t.Fatalf("expect nil error, but got %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/pet/medor":
+			_, err = w.Write([]byte(`{"name": "Medor", "category": {"name": "dog"}}`))
+			foundCalled = true
+		case "/pet/rex":
+			w.WriteHeader(http.StatusNotFound)
+			_, err = w.Write([]byte(`{"type": "PetNotFound", "cause": {"type": "NoSuchRow"}}`))
+			notFoundCalled = true
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+
+		if err != nil {
+			t.Errorf("expect nil error, but got %v", err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	integrationSuite.Run(t, alitest.RunParameters{URL: srv.URL})
+
+	if !foundCalled {
+		t.Fatal("external response reference not covered")
+	}
+
+	if !notFoundCalled {
+		t.Fatal("component response reference not covered")
+	}
+}
+
+func TestParseFileRecursiveSchemaRef(t *testing.T) {
+	spec := `
+info:
+  title: recursive external schema
+paths:
+  /tree:
+    get:
+      responses:
+        200:
+          x-ali-response:
+            expected:
+              $type: object
+          content:
+            application/json:
+              schema:
+                $ref: './dataset/refs/schemas/node.yaml#/Node'
+`
+
+	integrationSuite, err := alitest.ParseString(spec)
+
+	if err != nil {
+		t.Fatalf("expect nil error, but got %v", err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name": "root", "children": [{"name": "child", "children": [{"name": "leaf"}]}]}`))
+	})
+
+	report := integrationSuite.Run(t, alitest.RunParameters{Handler: handler})
+
+	if report.Totals.Passed != 1 {
+		t.Fatalf("expect 1 passed response, but got %+v", report.Totals)
+	}
+
+	output := runFailingTest(t, func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"name": "root", "children": [{"name": "child", "children": [{"name": 5}]}]}`))
+		})
+
+		integrationSuite.Run(t, alitest.RunParameters{Handler: handler})
+	})
+
+	if !strings.Contains(output, "#/children/0/children/0/name (type)") {
+		t.Errorf("expect the violation of the deepest node, but got :\n%s", output)
+	}
+}
+
+func TestParseFileRefsErrors(t *testing.T) {
+	tests := []struct {
+		description string
+		spec        string
+		err         string
+	}{
+		{
+			description: "circular reference",
+			spec: `
+paths:
+  /pet:
+    get:
+      parameters:
+      - $ref: './dataset/refs/circular.yaml#/components/parameters/PetId'
+`,
+			err: "circular reference #/components/parameters/PetId",
+		},
+		{
+			description: "unknown component",
+			spec: `
+paths:
+  /pet:
+    get:
+      parameters:
+      - $ref: '#/components/parameters/PetId'
+`,
+			err: "cannot resolve reference #/components/parameters/PetId : components not found",
+		},
+		{
+			description: "unexisting file",
+			spec: `
+paths:
+  /pet:
+    get:
+      parameters:
+      - $ref: './dataset/refs/do_not_exist.yaml#/PetId'
+`,
+			err: "open dataset/refs/do_not_exist.yaml: no such file or directory",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			_, err := alitest.ParseString(test.spec)
+
+			if err == nil || err.Error() != test.err {
+				t.Fatalf("expect error %s, but got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package alitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"testing"
+)
+
+// CoverageStatus is the outcome of the tests of a documented status code.
+type CoverageStatus string
+
+const (
+	// NotExercised means no request was sent, e.g. after a failed BeforeSuite hook
+	NotExercised CoverageStatus = "not exercised"
+	Passed       CoverageStatus = "passed"
+	Failed       CoverageStatus = "failed"
+	// Errored means the test failed before any request was sent, e.g. missing test data or a failed hook
+	Errored CoverageStatus = "errored"
+	Skipped CoverageStatus = "skipped"
+	// NoTestData means a request was sent, without any x-ali test data to check
+	NoTestData CoverageStatus = "no test data"
+)
+
+// CoverageReport tells, for each documented status code of the specification, how it was tested.
+type CoverageReport struct {
+	Title   string          `json:"title"`
+	Entries []CoverageEntry `json:"entries"`
+	Totals  CoverageTotals  `json:"totals"`
+	// Coverage is the percentage of documented status codes tested, passed or failed after
+	// at least one request was sent
+	Coverage float64 `json:"coverage"`
+}
+
+type CoverageEntry struct {
+	Path        string         `json:"path"`
+	Method      string         `json:"method"`
+	OperationID string         `json:"operationId"`
+	StatusCode  string         `json:"statusCode"`
+	Status      CoverageStatus `json:"status"`
+	// Exercised tells if at least one request was sent
+	Exercised bool `json:"exercised"`
+}
+
+type CoverageTotals struct {
+	Total        int `json:"total"`
+	Passed       int `json:"passed"`
+	Failed       int `json:"failed"`
+	Errored      int `json:"errored"`
+	Skipped      int `json:"skipped"`
+	NoTestData   int `json:"noTestData"`
+	NotExercised int `json:"notExercised"`
+}
+
+// WriteJSON writes the report as an indented json document.
+func (r CoverageReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteMarkdown writes the report as a markdown table, followed by the totals.
+func (r CoverageReport) WriteMarkdown(w io.Writer) error {
+	lines := []string{
+		fmt.Sprintf("# %s coverage report\n\n", r.Title),
+		fmt.Sprintf("Coverage: %.2f%% (%d/%d status codes tested)\n\n", r.Coverage, r.tested(), r.Totals.Total),
+		"| Path | Method | Operation | Status code | Result |\n",
+		"| --- | --- | --- | --- | --- |\n",
+	}
+	for _, entry := range r.Entries {
+		lines = append(lines, fmt.Sprintf("| %s | %s | %s | %s | %s |\n", entry.Path, entry.Method, entry.OperationID, entry.StatusCode, entry.Status))
+	}
+	lines = append(lines,
+		"\n| Passed | Failed | Errored | Skipped | No test data | Not exercised | Total |\n",
+		"| --- | --- | --- | --- | --- | --- | --- |\n",
+		fmt.Sprintf("| %d | %d | %d | %d | %d | %d | %d |\n", r.Totals.Passed, r.Totals.Failed, r.Totals.Errored, r.Totals.Skipped, r.Totals.NoTestData, r.Totals.NotExercised, r.Totals.Total),
+	)
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newCoverage lists every documented status code as not exercised, in a deterministic order.
+func newCoverage(doc OpenApiDocument) []*CoverageEntry {
+	var entries []*CoverageEntry
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		for _, op := range doc.Paths[path].operations() {
+			for _, statusCode := range op.operation.Responses.StatusCodes() {
+				entries = append(entries, &CoverageEntry{
+					Path:        path,
+					Method:      op.verb,
+					OperationID: op.operation.OperationID,
+					StatusCode:  statusCode,
+					Status:      NotExercised,
+				})
+			}
+		}
+	}
+	return entries
+}
+
+// coverageEntry returns the entry of a documented status code.
+func (r *suiteRun) coverageEntry(path, method, statusCode string) *CoverageEntry {
+	for _, entry := range r.coverage {
+		if entry.Path == path && entry.Method == method && entry.StatusCode == statusCode {
+			return entry
+		}
+	}
+	// not documented, keep track of it anyway
+	entry := &CoverageEntry{Path: path, Method: method, StatusCode: statusCode, Status: NotExercised}
+	r.coverage = append(r.coverage, entry)
+	return entry
+}
+
+// skipOperation marks all the status codes of the operation as skipped.
+func (r *suiteRun) skipOperation(path, method string, operation OpenApiOperation) {
+	for _, statusCode := range operation.Responses.StatusCodes() {
+		r.coverageEntry(path, method, statusCode).Status = Skipped
+	}
+}
+
+// failOperation marks all the status codes of the operation as errored, when its tests cannot run.
+func (r *suiteRun) failOperation(path, method string, operation OpenApiOperation) {
+	for _, statusCode := range operation.Responses.StatusCodes() {
+		r.coverageEntry(path, method, statusCode).Status = Errored
+	}
+}
+
+// complete sets the status of the entry from the outcome of its test.
+func (e *CoverageEntry) complete(t *testing.T, hasTestData bool) {
+	switch {
+	case t.Skipped():
+		e.Status = Skipped
+	case t.Failed() && !e.Exercised:
+		e.Status = Errored
+	case t.Failed():
+		e.Status = Failed
+	case !e.Exercised:
+		e.Status = NotExercised
+	case !hasTestData:
+		e.Status = NoTestData
+	default:
+		e.Status = Passed
+	}
+}
+
+func (r *suiteRun) report(title string) CoverageReport {
+	report := CoverageReport{Title: title, Entries: []CoverageEntry{}}
+
+	for _, entry := range r.coverage {
+		report.Entries = append(report.Entries, *entry)
+		report.Totals.Total++
+		switch entry.Status {
+		case Passed:
+			report.Totals.Passed++
+		case Failed:
+			report.Totals.Failed++
+		case Errored:
+			report.Totals.Errored++
+		case Skipped:
+			report.Totals.Skipped++
+		case NoTestData:
+			report.Totals.NoTestData++
+		default:
+			report.Totals.NotExercised++
+		}
+	}
+
+	if report.Totals.Total > 0 {
+		report.Coverage = float64(report.tested()) * 100 / float64(report.Totals.Total)
+	}
+	return report
+}
+
+// tested counts the status codes which passed or failed after at least one request was sent.
+func (r CoverageReport) tested() int {
+	var tested int
+	for _, entry := range r.Entries {
+		if entry.Exercised && (entry.Status == Passed || entry.Status == Failed) {
+			tested++
+		}
+	}
+	return tested
+}
@@ -0,0 +1,233 @@
+package alitest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/toolzup/alitest"
+)
+
+func TestRunCoverageReport(t *testing.T) {
+	integrationSuite, err := alitest.ParseString(skipSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write([]byte(`[]`)); err != nil {
+			t.Errorf("expect nil error, but got %v", err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	report := integrationSuite.Run(t, alitest.RunParameters{URL: srv.URL})
+
+	expected := []alitest.CoverageEntry{
+		{Path: "/pets", Method: http.MethodGet, OperationID: "listPets", StatusCode: "200", Status: alitest.Passed, Exercised: true},
+		{Path: "/pets", Method: http.MethodGet, OperationID: "listPets", StatusCode: "404", Status: alitest.Skipped},
+		{Path: "/pets", Method: http.MethodDelete, OperationID: "deletePets", StatusCode: "204", Status: alitest.Skipped},
+	}
+
+	if len(report.Entries) != len(expected) {
+		t.Fatalf("expect %d entries, but got %v", len(expected), report.Entries)
+	}
+
+	for i, entry := range expected {
+		if report.Entries[i] != entry {
+			t.Errorf("expect entry %d to be %+v, but got %+v", i, entry, report.Entries[i])
+		}
+	}
+
+	if report.Totals != (alitest.CoverageTotals{Total: 3, Passed: 1, Skipped: 2}) {
+		t.Errorf("unexpected totals %+v", report.Totals)
+	}
+
+	if report.Coverage < 33.33 || report.Coverage > 33.34 {
+		t.Errorf("expect a 33.33%% coverage, but got %.2f", report.Coverage)
+	}
+}
+
+func TestRunCoverageReportErrored(t *testing.T) {
+	output := runFailingTest(t, func(t *testing.T) {
+		integrationSuite, err := alitest.ParseString(skipSpec)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`[]`))
+		})
+
+		report := integrationSuite.Run(t, alitest.RunParameters{
+			Handler: handler,
+			BeforeOperation: map[string]alitest.OperationHook{
+				"listPets": func(ctx context.Context, parameters alitest.OperationHookParameters) error {
+					return errors.New("cannot seed the pets")
+				},
+			},
+		})
+
+		var buffer bytes.Buffer
+		if err := report.WriteMarkdown(&buffer); err != nil {
+			t.Error(err)
+		}
+		t.Log(buffer.String())
+	})
+
+	for _, expected := range []string{
+		"Coverage: 0.00% (0/3 status codes tested)",
+		"| /pets | GET | listPets | 200 | errored |",
+		"| 0 | 0 | 1 | 2 | 0 | 0 | 3 |",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expect %q in the output, but got :\n%s", expected, output)
+		}
+	}
+}
+
+func TestRunCoverageReportUnresolvedServer(t *testing.T) {
+	output := runFailingTest(t, func(t *testing.T) {
+		integrationSuite, err := alitest.ParseString(`
+openapi: 3.0.1
+info:
+  title: unresolved server
+servers:
+  - url: https://{region}.example.com
+    variables:
+      region:
+        default: eu
+        enum: [eu, us]
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      responses:
+        200:
+          description: the pets
+        404:
+          description: no pet
+`)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`[]`))
+		})
+
+		report := integrationSuite.Run(t, alitest.RunParameters{
+			Handler:         handler,
+			ServerVariables: map[string]string{"region": "asia"},
+		})
+
+		var buffer bytes.Buffer
+		if err := report.WriteMarkdown(&buffer); err != nil {
+			t.Error(err)
+		}
+		t.Log(buffer.String())
+	})
+
+	for _, expected := range []string{
+		"Cannot resolve the server of listPets",
+		"| /pets | GET | listPets | 200 | errored |",
+		"| /pets | GET | listPets | 404 | errored |",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expect %q in the output, but got :\n%s", expected, output)
+		}
+	}
+}
+
+func TestCoverageReportWrite(t *testing.T) {
+	report := alitest.CoverageReport{
+		Title: "pets",
+		Entries: []alitest.CoverageEntry{
+			{Path: "/pets", Method: http.MethodGet, OperationID: "listPets", StatusCode: "200", Status: alitest.Passed, Exercised: true},
+			{Path: "/pets", Method: http.MethodPost, OperationID: "addPet", StatusCode: "201", Status: alitest.NoTestData, Exercised: true},
+		},
+		Totals:   alitest.CoverageTotals{Total: 2, Passed: 1, NoTestData: 1},
+		Coverage: 50,
+	}
+
+	tests := []struct {
+		name     string
+		write    func(*bytes.Buffer) error
+		expected string
+	}{
+		{
+			name:  "json",
+			write: func(buffer *bytes.Buffer) error { return report.WriteJSON(buffer) },
+			expected: `{
+  "title": "pets",
+  "entries": [
+    {
+      "path": "/pets",
+      "method": "GET",
+      "operationId": "listPets",
+      "statusCode": "200",
+      "status": "passed",
+      "exercised": true
+    },
+    {
+      "path": "/pets",
+      "method": "POST",
+      "operationId": "addPet",
+      "statusCode": "201",
+      "status": "no test data",
+      "exercised": true
+    }
+  ],
+  "totals": {
+    "total": 2,
+    "passed": 1,
+    "failed": 0,
+    "errored": 0,
+    "skipped": 0,
+    "noTestData": 1,
+    "notExercised": 0
+  },
+  "coverage": 50
+}
+`,
+		},
+		{
+			name:  "markdown",
+			write: func(buffer *bytes.Buffer) error { return report.WriteMarkdown(buffer) },
+			expected: `# pets coverage report
+
+Coverage: 50.00% (1/2 status codes tested)
+
+| Path | Method | Operation | Status code | Result |
+| --- | --- | --- | --- | --- |
+| /pets | GET | listPets | 200 | passed |
+| /pets | POST | addPet | 201 | no test data |
+
+| Passed | Failed | Errored | Skipped | No test data | Not exercised | Total |
+| --- | --- | --- | --- | --- | --- | --- |
+| 1 | 0 | 0 | 0 | 1 | 0 | 2 |
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			if err := test.write(&buffer); err != nil {
+				t.Fatalf("expect nil error, got %v", err)
+			}
+
+			if buffer.String() != test.expected {
+				t.Fatalf("expect\n%s\ngot\n%s", test.expected, buffer.String())
+			}
+		})
+	}
+}
@@ -1,7 +1,85 @@
 package alitest
 
+import (
+	"context"
+	"net/http"
+)
+
+// suiteRun holds the state shared by all the tests of a suite run.
+type suiteRun struct {
+	parameters      RunParameters
+	security        []OpenApiSecurityRequirement
+	securitySchemes map[string]OpenApiSecurityScheme
+	servers         []OpenApiServer
+	// tokens caches the oauth2 access tokens, by scheme name and scopes
+	tokens   map[string]string
+	client   *http.Client
+	coverage []*CoverageEntry
+	// variables are the values captured by x-ali-capture, by name
+	variables map[string]interface{}
+	// ctx is given to the hooks, it is canceled once the run is over
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newSuiteRun(doc OpenApiDocument, parameters RunParameters) *suiteRun {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &suiteRun{
+		parameters:      parameters,
+		security:        doc.Security,
+		securitySchemes: doc.Components.SecuritySchemes,
+		servers:         doc.Servers,
+		tokens:          map[string]string{},
+		client:          newHTTPClient(parameters),
+		coverage:        newCoverage(doc),
+		variables:       map[string]interface{}{},
+		ctx:             ctx,
+		cancel:          cancel,
+	}
+}
+
 type operationRunContext struct {
-	url        string
-	verb       string
-	parameters []OpenApiParameter
+	run         *suiteRun
+	coverage    *CoverageEntry
+	path        string
+	operationID string
+	url         string
+	verb        string
+	parameters  []OpenApiParameter
+	requestBody *OpenApiRequestBody
+	responses   OpenApiResponses
+	security    []OpenApiSecurityRequirement
+}
+
+func newOperationRunContext(run *suiteRun, path, url, verb string, operation OpenApiOperation) operationRunContext {
+	ctx := operationRunContext{run: run, path: path, operationID: operation.OperationID, url: url, verb: verb, parameters: operation.Parameters, requestBody: operation.RequestBody, responses: operation.Responses, security: run.security}
+	if operation.Security != nil {
+		ctx.security = operation.Security
+	}
+	return ctx
+}
+
+// do sends a request with the client of the run. The caller must close the response body.
+func (r *suiteRun) do(request *http.Request) (*http.Response, error) {
+	return r.client.Do(request.WithContext(r.ctx))
+}
+
+// requestContentType returns the media type used to send a request body, and its documented content.
+func (ctx operationRunContext) requestContentType() (string, OpenApiResponseContent) {
+	if ctx.requestBody == nil {
+		return "application/json", OpenApiResponseContent{}
+	}
+	return ctx.requestBody.MediaType()
+}
+
+// verbAcceptsRequestBody tells if a request body can be sent with the given verb.
+// TRACE requests must not include a body (RFC 9110).
+func verbAcceptsRequestBody(verb string) bool {
+	return verb != http.MethodTrace
+}
+
+// verbReturnsResponseBody tells if a response to the given verb carries a body.
+// HEAD responses never carry one.
+func verbReturnsResponseBody(verb string) bool {
+	return verb != http.MethodHead
 }
@@ -0,0 +1,607 @@
+package alitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+const componentSchemaPrefix = "#/components/schemas/"
+
+// Schema is the subset of the OpenAPI schema object used to validate payloads.
+type Schema struct {
+	Ref                  string                `json:"$ref,omitempty" yaml:"$ref"`
+	Type                 SchemaTypes           `json:"type,omitempty" yaml:"type"`
+	Format               string                `json:"format,omitempty" yaml:"format"`
+	Nullable             bool                  `json:"nullable,omitempty" yaml:"nullable"`
+	ReadOnly             bool                  `json:"readOnly,omitempty" yaml:"readOnly"`
+	WriteOnly            bool                  `json:"writeOnly,omitempty" yaml:"writeOnly"`
+	Enum                 []interface{}         `json:"enum,omitempty" yaml:"enum"`
+	Minimum              *float64              `json:"minimum,omitempty" yaml:"minimum"`
+	Maximum              *float64              `json:"maximum,omitempty" yaml:"maximum"`
+	ExclusiveMinimum     *ExclusiveBound       `json:"exclusiveMinimum,omitempty" yaml:"exclusiveMinimum"`
+	ExclusiveMaximum     *ExclusiveBound       `json:"exclusiveMaximum,omitempty" yaml:"exclusiveMaximum"`
+	MinLength            *int                  `json:"minLength,omitempty" yaml:"minLength"`
+	MaxLength            *int                  `json:"maxLength,omitempty" yaml:"maxLength"`
+	Pattern              string                `json:"pattern,omitempty" yaml:"pattern"`
+	Items                *Schema               `json:"items,omitempty" yaml:"items"`
+	MinItems             *int                  `json:"minItems,omitempty" yaml:"minItems"`
+	MaxItems             *int                  `json:"maxItems,omitempty" yaml:"maxItems"`
+	Required             []string              `json:"required,omitempty" yaml:"required"`
+	Properties           map[string]*Schema    `json:"properties,omitempty" yaml:"properties"`
+	AdditionalProperties *AdditionalProperties `json:"additionalProperties,omitempty" yaml:"additionalProperties"`
+	AllOf                []*Schema             `json:"allOf,omitempty" yaml:"allOf"`
+	OneOf                []*Schema             `json:"oneOf,omitempty" yaml:"oneOf"`
+	AnyOf                []*Schema             `json:"anyOf,omitempty" yaml:"anyOf"`
+
+	// resolved is the schema targeted by Ref, linked when the document is parsed
+	resolved *Schema
+	// pattern is the compiled Pattern, compiled when the document is parsed
+	pattern *regexp.Regexp
+}
+
+// SchemaTypes holds the type(s) of a schema. OpenAPI 3.0 uses a single type,
+// OpenAPI 3.1 accepts a list of types.
+type SchemaTypes []string
+
+func (s *SchemaTypes) UnmarshalYAML(data *yaml.Node) error {
+	if data.Kind == yaml.ScalarNode {
+		var schemaType string
+		if err := data.Decode(&schemaType); err != nil {
+			return err
+		}
+		*s = SchemaTypes{schemaType}
+		return nil
+	}
+
+	var schemaTypes []string
+	if err := data.Decode(&schemaTypes); err != nil {
+		return err
+	}
+	*s = schemaTypes
+	return nil
+}
+
+// AdditionalProperties is either a boolean or a schema applied to undeclared properties.
+type AdditionalProperties struct {
+	Forbidden bool
+	Schema    *Schema
+}
+
+func (a *AdditionalProperties) UnmarshalYAML(data *yaml.Node) error {
+	var allowed bool
+	if data.Kind == yaml.ScalarNode && data.Decode(&allowed) == nil {
+		a.Forbidden = !allowed
+		return nil
+	}
+
+	var schema Schema
+	if err := data.Decode(&schema); err != nil {
+		return err
+	}
+	a.Schema = &schema
+	return nil
+}
+
+func (a AdditionalProperties) MarshalJSON() ([]byte, error) {
+	if a.Schema != nil {
+		return json.Marshal(a.Schema)
+	}
+	return json.Marshal(!a.Forbidden)
+}
+
+// ExclusiveBound is either an OpenAPI 3.0 boolean, making minimum or maximum exclusive,
+// or an OpenAPI 3.1 number, the exclusive bound itself.
+type ExclusiveBound struct {
+	Exclusive bool
+	Limit     *float64
+}
+
+func (b *ExclusiveBound) UnmarshalYAML(data *yaml.Node) error {
+	if data.Kind == yaml.ScalarNode && data.Decode(&b.Exclusive) == nil {
+		return nil
+	}
+
+	var limit float64
+	if err := data.Decode(&limit); err != nil {
+		return err
+	}
+	b.Limit = &limit
+	return nil
+}
+
+func (b ExclusiveBound) MarshalJSON() ([]byte, error) {
+	if b.Limit != nil {
+		return json.Marshal(*b.Limit)
+	}
+	return json.Marshal(b.Exclusive)
+}
+
+// exclusive tells if the bound makes minimum or maximum exclusive, the 3.0 form.
+func (b *ExclusiveBound) exclusive() bool {
+	return b != nil && b.Exclusive
+}
+
+// limit returns the exclusive bound of the 3.1 form.
+func (b *ExclusiveBound) limit() (float64, bool) {
+	if b == nil || b.Limit == nil {
+		return 0, false
+	}
+	return *b.Limit, true
+}
+
+// SchemaViolation describes a payload part not respecting its schema.
+type SchemaViolation struct {
+	// Pointer is the json pointer of the invalid value
+	Pointer string
+	// Keyword is the violated schema keyword
+	Keyword string
+	Message string
+}
+
+func (v SchemaViolation) String() string {
+	return fmt.Sprintf("#%s (%s): %s", v.Pointer, v.Keyword, v.Message)
+}
+
+// payloadDirection tells if a payload is sent or received, readOnly and writeOnly
+// properties are only required in one direction.
+type payloadDirection int
+
+const (
+	responsePayload payloadDirection = iota
+	requestPayload
+)
+
+// Validate checks a decoded json response payload against the schema.
+func (s *Schema) Validate(value interface{}) []SchemaViolation {
+	return s.validate(value, "", responsePayload)
+}
+
+// ValidateRequest checks a decoded json request payload against the schema.
+func (s *Schema) ValidateRequest(value interface{}) []SchemaViolation {
+	return s.validate(value, "", requestPayload)
+}
+
+func (s *Schema) validate(value interface{}, pointer string, direction payloadDirection) []SchemaViolation {
+	if s == nil {
+		return nil
+	}
+
+	if s.Ref != "" {
+		if s.resolved == nil {
+			return []SchemaViolation{{Pointer: pointer, Keyword: "$ref", Message: fmt.Sprintf("unresolved reference %s", s.Ref)}}
+		}
+		return s.resolved.validate(value, pointer, direction)
+	}
+
+	var violations []SchemaViolation
+
+	violations = append(violations, s.validateComposition(value, pointer, direction)...)
+
+	if value == nil {
+		if len(s.Type) > 0 && !s.Nullable && !s.Type.contains("null") {
+			violations = append(violations, SchemaViolation{Pointer: pointer, Keyword: "type", Message: fmt.Sprintf("expect %s, got null", s.Type)})
+		}
+		return violations
+	}
+
+	if len(s.Type) > 0 && !s.Type.accepts(value) {
+		return append(violations, SchemaViolation{Pointer: pointer, Keyword: "type", Message: fmt.Sprintf("expect %s, got %s", s.Type, jsonType(value))})
+	}
+
+	if len(s.Enum) > 0 && !s.enumContains(value) {
+		violations = append(violations, SchemaViolation{Pointer: pointer, Keyword: "enum", Message: fmt.Sprintf("%v is not one of %v", value, s.Enum)})
+	}
+
+	switch typedValue := value.(type) {
+	case float64:
+		violations = append(violations, s.validateNumber(typedValue, pointer)...)
+	case string:
+		violations = append(violations, s.validateString(typedValue, pointer)...)
+	case []interface{}:
+		violations = append(violations, s.validateArray(typedValue, pointer, direction)...)
+	case map[string]interface{}:
+		violations = append(violations, s.validateObject(typedValue, pointer, direction)...)
+	}
+
+	return violations
+}
+
+func (s *Schema) validateComposition(value interface{}, pointer string, direction payloadDirection) []SchemaViolation {
+	var violations []SchemaViolation
+
+	for _, schema := range s.AllOf {
+		violations = append(violations, schema.validate(value, pointer, direction)...)
+	}
+
+	if len(s.AnyOf) > 0 && countMatchingSchemas(s.AnyOf, value, pointer, direction) == 0 {
+		violations = append(violations, SchemaViolation{Pointer: pointer, Keyword: "anyOf", Message: "value does not match any schema"})
+	}
+
+	if len(s.OneOf) > 0 {
+		if matching := countMatchingSchemas(s.OneOf, value, pointer, direction); matching != 1 {
+			violations = append(violations, SchemaViolation{Pointer: pointer, Keyword: "oneOf", Message: fmt.Sprintf("value matches %d schemas, expect exactly one", matching)})
+		}
+	}
+
+	return violations
+}
+
+func countMatchingSchemas(schemas []*Schema, value interface{}, pointer string, direction payloadDirection) int {
+	var count int
+	for _, schema := range schemas {
+		if len(schema.validate(value, pointer, direction)) == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Schema) validateNumber(value float64, pointer string) []SchemaViolation {
+	var violations []SchemaViolation
+
+	if s.Minimum != nil && (value < *s.Minimum || (s.ExclusiveMinimum.exclusive() && value == *s.Minimum)) {
+		violations = append(violations, SchemaViolation{Pointer: pointer, Keyword: "minimum", Message: fmt.Sprintf("%v is lower than %v", value, *s.Minimum)})
+	}
+
+	if limit, found := s.ExclusiveMinimum.limit(); found && value <= limit {
+		violations = append(violations, SchemaViolation{Pointer: pointer, Keyword: "exclusiveMinimum", Message: fmt.Sprintf("%v is not greater than %v", value, limit)})
+	}
+
+	if s.Maximum != nil && (value > *s.Maximum || (s.ExclusiveMaximum.exclusive() && value == *s.Maximum)) {
+		violations = append(violations, SchemaViolation{Pointer: pointer, Keyword: "maximum", Message: fmt.Sprintf("%v is greater than %v", value, *s.Maximum)})
+	}
+
+	if limit, found := s.ExclusiveMaximum.limit(); found && value >= limit {
+		violations = append(violations, SchemaViolation{Pointer: pointer, Keyword: "exclusiveMaximum", Message: fmt.Sprintf("%v is not lower than %v", value, limit)})
+	}
+
+	return violations
+}
+
+func (s *Schema) validateString(value string, pointer string) []SchemaViolation {
+	var violations []SchemaViolation
+	length := utf8.RuneCountInString(value)
+
+	if s.MinLength != nil && length < *s.MinLength {
+		violations = append(violations, SchemaViolation{Pointer: pointer, Keyword: "minLength", Message: fmt.Sprintf("length %d is lower than %d", length, *s.MinLength)})
+	}
+
+	if s.MaxLength != nil && length > *s.MaxLength {
+		violations = append(violations, SchemaViolation{Pointer: pointer, Keyword: "maxLength", Message: fmt.Sprintf("length %d is greater than %d", length, *s.MaxLength)})
+	}
+
+	if s.Pattern != "" {
+		pattern, err := s.compilePattern()
+		if err != nil {
+			violations = append(violations, SchemaViolation{Pointer: pointer, Keyword: "pattern", Message: err.Error()})
+		} else if !pattern.MatchString(value) {
+			violations = append(violations, SchemaViolation{Pointer: pointer, Keyword: "pattern", Message: fmt.Sprintf("%q does not match %s", value, s.Pattern)})
+		}
+	}
+
+	return violations
+}
+
+// compilePattern returns the compiled Pattern, compiling it on first use.
+func (s *Schema) compilePattern() (*regexp.Regexp, error) {
+	if s.pattern != nil {
+		return s.pattern, nil
+	}
+	pattern, err := regexp.Compile(s.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s : %v", s.Pattern, err)
+	}
+	s.pattern = pattern
+	return pattern, nil
+}
+
+func (s *Schema) validateArray(value []interface{}, pointer string, direction payloadDirection) []SchemaViolation {
+	var violations []SchemaViolation
+
+	if s.MinItems != nil && len(value) < *s.MinItems {
+		violations = append(violations, SchemaViolation{Pointer: pointer, Keyword: "minItems", Message: fmt.Sprintf("%d items is lower than %d", len(value), *s.MinItems)})
+	}
+
+	if s.MaxItems != nil && len(value) > *s.MaxItems {
+		violations = append(violations, SchemaViolation{Pointer: pointer, Keyword: "maxItems", Message: fmt.Sprintf("%d items is greater than %d", len(value), *s.MaxItems)})
+	}
+
+	for index, item := range value {
+		violations = append(violations, s.Items.validate(item, fmt.Sprintf("%s/%d", pointer, index), direction)...)
+	}
+
+	return violations
+}
+
+func (s *Schema) validateObject(value map[string]interface{}, pointer string, direction payloadDirection) []SchemaViolation {
+	var violations []SchemaViolation
+
+	for _, name := range s.Required {
+		if s.Properties[name].ignoredIn(direction) {
+			continue
+		}
+		if _, present := value[name]; !present {
+			violations = append(violations, SchemaViolation{Pointer: pointer, Keyword: "required", Message: fmt.Sprintf("missing property %s", name)})
+		}
+	}
+
+	// sort the names to report violations in a stable order
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		propertyPointer := pointer + "/" + escapeJSONPointer(name)
+		if property, declared := s.Properties[name]; declared {
+			violations = append(violations, property.validate(value[name], propertyPointer, direction)...)
+			continue
+		}
+
+		if s.AdditionalProperties == nil {
+			continue
+		}
+
+		if s.AdditionalProperties.Forbidden {
+			violations = append(violations, SchemaViolation{Pointer: propertyPointer, Keyword: "additionalProperties", Message: fmt.Sprintf("property %s is not allowed", name)})
+		} else {
+			violations = append(violations, s.AdditionalProperties.Schema.validate(value[name], propertyPointer, direction)...)
+		}
+	}
+
+	return violations
+}
+
+// ignoredIn tells if a required property can be absent: a readOnly property is not
+// sent in requests, a writeOnly property is not returned in responses.
+func (s *Schema) ignoredIn(direction payloadDirection) bool {
+	if s == nil {
+		return false
+	}
+	if s.resolved != nil {
+		return s.resolved.ignoredIn(direction)
+	}
+	return (direction == requestPayload && s.ReadOnly) || (direction == responsePayload && s.WriteOnly)
+}
+
+func (s *Schema) enumContains(value interface{}) bool {
+	for _, candidate := range s.Enum {
+		if reflect.DeepEqual(normalizeJSON(candidate), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t SchemaTypes) contains(schemaType string) bool {
+	for _, candidate := range t {
+		if candidate == schemaType {
+			return true
+		}
+	}
+	return false
+}
+
+func (t SchemaTypes) accepts(value interface{}) bool {
+	actual := jsonType(value)
+	if t.contains(actual) {
+		return true
+	}
+	// a number without decimals is also an integer
+	if actual == "number" && t.contains("integer") {
+		number := value.(float64)
+		return number == math.Trunc(number)
+	}
+	return false
+}
+
+func (t SchemaTypes) String() string {
+	return strings.Join(t, " or ")
+}
+
+// jsonType returns the json schema type of a decoded json value.
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// normalizeJSON converts a yaml decoded value to its json decoded counterpart.
+func normalizeJSON(value interface{}) interface{} {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(encoded, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
+
+// resolveSchemaRefs links every schema reference of the document to its target.
+func (d *OpenApiDocument) resolveSchemaRefs() error {
+	visited := map[*Schema]bool{}
+
+	for _, schema := range d.Components.Schemas {
+		if err := d.resolveSchemaRef(schema, visited); err != nil {
+			return err
+		}
+	}
+	for _, ref := range sortedKeys(d.externalSchemas) {
+		if err := d.resolveSchemaRef(d.externalSchemas[ref], visited); err != nil {
+			return err
+		}
+	}
+
+	responses := make([]*OpenApiResponse, 0, len(d.Components.Responses))
+	for _, response := range d.Components.Responses {
+		responses = append(responses, response)
+	}
+	for _, path := range d.Paths {
+		for _, op := range path.operations() {
+			for _, response := range op.operation.Responses {
+				responses = append(responses, response)
+			}
+		}
+	}
+
+	contents := []map[string]OpenApiResponseContent{}
+	for _, response := range responses {
+		contents = append(contents, response.Content)
+		for _, header := range response.Headers {
+			if err := d.resolveSchemaRef(header.Schema, visited); err != nil {
+				return err
+			}
+		}
+	}
+	for _, header := range d.Components.Headers {
+		if err := d.resolveSchemaRef(header.Schema, visited); err != nil {
+			return err
+		}
+	}
+
+	for _, requestBody := range d.Components.RequestBodies {
+		contents = append(contents, requestBody.Content)
+	}
+	for _, path := range d.Paths {
+		for _, op := range path.operations() {
+			if op.operation.RequestBody != nil {
+				contents = append(contents, op.operation.RequestBody.Content)
+			}
+		}
+	}
+
+	for _, content := range contents {
+		for _, mediaType := range content {
+			if err := d.resolveSchemaRef(mediaType.Schema, visited); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// schemaAt returns the schema targeted by a reference to a component schema, or to one of its subschemas.
+// The schemas of other files are found by their file qualified reference.
+func (d *OpenApiDocument) schemaAt(ref string, following map[string]bool) (*Schema, error) {
+	if schema, found := d.externalSchemas[ref]; found {
+		return schema, nil
+	}
+
+	unresolved := fmt.Errorf("cannot resolve schema reference %s", ref)
+	if !strings.HasPrefix(ref, componentSchemaPrefix) || following[ref] {
+		return nil, unresolved
+	}
+	following[ref] = true
+
+	tokens, err := parseJSONPointer("/" + strings.TrimPrefix(ref, componentSchemaPrefix))
+	if err != nil {
+		return nil, unresolved
+	}
+
+	schema := d.Components.Schemas[tokens[0]]
+	for index := 1; schema != nil && index < len(tokens); index++ {
+		// a subschema of a reference is a subschema of its target
+		for schema.Ref != "" {
+			if schema, err = d.schemaAt(schema.Ref, following); err != nil {
+				return nil, err
+			}
+		}
+
+		token := tokens[index]
+		switch {
+		case token == "items":
+			schema = schema.Items
+		case token == "additionalProperties" && schema.AdditionalProperties != nil:
+			schema = schema.AdditionalProperties.Schema
+		case (token == "properties" || token == "allOf" || token == "oneOf" || token == "anyOf") && index+1 < len(tokens):
+			index++
+			schema = subschema(schema, token, tokens[index])
+		default:
+			schema = nil
+		}
+	}
+
+	if schema == nil {
+		return nil, unresolved
+	}
+	return schema, nil
+}
+
+// subschema returns the named property, or the indexed schema of a composition.
+func subschema(schema *Schema, keyword, token string) *Schema {
+	if keyword == "properties" {
+		return schema.Properties[token]
+	}
+
+	composition := map[string][]*Schema{"allOf": schema.AllOf, "oneOf": schema.OneOf, "anyOf": schema.AnyOf}[keyword]
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index >= len(composition) {
+		return nil
+	}
+	return composition[index]
+}
+
+func (d *OpenApiDocument) resolveSchemaRef(schema *Schema, visited map[*Schema]bool) error {
+	if schema == nil || visited[schema] {
+		return nil
+	}
+	visited[schema] = true
+
+	if schema.Ref != "" {
+		target, err := d.schemaAt(schema.Ref, map[string]bool{})
+		if err != nil {
+			return err
+		}
+		schema.resolved = target
+	}
+
+	if schema.Pattern != "" {
+		if _, err := schema.compilePattern(); err != nil {
+			return err
+		}
+	}
+
+	children := []*Schema{schema.Items}
+	children = append(children, schema.AllOf...)
+	children = append(children, schema.OneOf...)
+	children = append(children, schema.AnyOf...)
+	for _, property := range schema.Properties {
+		children = append(children, property)
+	}
+	if schema.AdditionalProperties != nil {
+		children = append(children, schema.AdditionalProperties.Schema)
+	}
+
+	for _, child := range children {
+		if err := d.resolveSchemaRef(child, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,353 @@
+package alitest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/toolzup/alitest"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		schema     string
+		payload    string
+		violations []string
+	}{
+		{
+			name:    "valid object",
+			schema:  "type: object\nrequired: [name]\nproperties:\n  name:\n    type: string\n  age:\n    type: integer",
+			payload: `{"name": "Medor", "age": 5}`,
+		},
+		{
+			name:       "type mismatch",
+			schema:     "type: object\nproperties:\n  age:\n    type: integer",
+			payload:    `{"age": "five"}`,
+			violations: []string{"#/age (type): expect integer, got string"},
+		},
+		{
+			name:       "integer with decimals",
+			schema:     "type: integer",
+			payload:    `5.5`,
+			violations: []string{"# (type): expect integer, got number"},
+		},
+		{
+			name:       "missing required property",
+			schema:     "type: object\nrequired: [id, name]",
+			payload:    `{"name": "Medor"}`,
+			violations: []string{"# (required): missing property id"},
+		},
+		{
+			name:       "enum",
+			schema:     "type: string\nenum: [available, sold]",
+			payload:    `"pending"`,
+			violations: []string{"# (enum): pending is not one of [available sold]"},
+		},
+		{
+			name:       "minimum and maximum",
+			schema:     "type: array\nitems:\n  type: number\n  minimum: 1\n  maximum: 10\n  exclusiveMaximum: true",
+			payload:    `[0, 5, 10]`,
+			violations: []string{"#/0 (minimum): 0 is lower than 1", "#/2 (maximum): 10 is greater than 10"},
+		},
+		{
+			name:       "openapi 3.1 exclusive minimum and maximum",
+			schema:     "type: array\nitems:\n  type: number\n  exclusiveMinimum: 0\n  exclusiveMaximum: 10",
+			payload:    `[0, 5, 10]`,
+			violations: []string{"#/0 (exclusiveMinimum): 0 is not greater than 0", "#/2 (exclusiveMaximum): 10 is not lower than 10"},
+		},
+		{
+			name:       "string length and pattern",
+			schema:     "type: string\nminLength: 3\npattern: '^[a-z]+$'",
+			payload:    `"M"`,
+			violations: []string{"# (minLength): length 1 is lower than 3", `# (pattern): "M" does not match ^[a-z]+$`},
+		},
+		{
+			name:       "array size",
+			schema:     "type: array\nmaxItems: 1",
+			payload:    `[1, 2]`,
+			violations: []string{"# (maxItems): 2 items is greater than 1"},
+		},
+		{
+			name:       "forbidden additional properties",
+			schema:     "type: object\nadditionalProperties: false\nproperties:\n  name:\n    type: string",
+			payload:    `{"name": "Medor", "a/b": 5}`,
+			violations: []string{"#/a~1b (additionalProperties): property a/b is not allowed"},
+		},
+		{
+			name:       "additional properties schema",
+			schema:     "type: object\nadditionalProperties:\n  type: integer",
+			payload:    `{"medor": 5, "rex": "five"}`,
+			violations: []string{"#/rex (type): expect integer, got string"},
+		},
+		{
+			name:       "null value",
+			schema:     "type: object\nproperties:\n  name:\n    type: string\n  tag:\n    type: string\n    nullable: true",
+			payload:    `{"name": null, "tag": null}`,
+			violations: []string{"#/name (type): expect string, got null"},
+		},
+		{
+			name:    "null type",
+			schema:  "type: [string, 'null']",
+			payload: `null`,
+		},
+		{
+			name:       "allOf",
+			schema:     "allOf:\n- required: [name]\n- required: [id]",
+			payload:    `{"name": "Medor"}`,
+			violations: []string{"# (required): missing property id"},
+		},
+		{
+			name:       "anyOf",
+			schema:     "anyOf:\n- type: string\n- type: integer",
+			payload:    `true`,
+			violations: []string{"# (anyOf): value does not match any schema"},
+		},
+		{
+			name:       "oneOf",
+			schema:     "oneOf:\n- type: number\n- type: integer",
+			payload:    `5`,
+			violations: []string{"# (oneOf): value matches 2 schemas, expect exactly one"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var schema alitest.Schema
+			var payload interface{}
+
+			if err := yaml.Unmarshal([]byte(test.schema), &schema); err != nil {
+				t.Fatalf("expect nil error, got %v", err)
+			}
+
+			if err := json.Unmarshal([]byte(test.payload), &payload); err != nil {
+				t.Fatalf("expect nil error, got %v", err)
+			}
+
+			var violations []string
+			for _, violation := range schema.Validate(payload) {
+				violations = append(violations, violation.String())
+			}
+
+			if strings.Join(violations, "\n") != strings.Join(test.violations, "\n") {
+				t.Fatalf("expect violations %v, got %v", test.violations, violations)
+			}
+		})
+	}
+}
+
+func TestSchemaValidateDirection(t *testing.T) {
+	var schema alitest.Schema
+
+	err := yaml.Unmarshal([]byte(`
+type: object
+required: [id, name, password]
+properties:
+  id:
+    type: integer
+    readOnly: true
+  name:
+    type: string
+  password:
+    type: string
+    writeOnly: true
+`), &schema)
+
+	if err != nil {
+		t.Fatalf("expect nil error, got %v", err)
+	}
+
+	request := map[string]interface{}{"name": "Medor", "password": "secret"}
+	if violations := schema.ValidateRequest(request); len(violations) != 0 {
+		t.Fatalf("expect no violation on request, got %v", violations)
+	}
+
+	response := map[string]interface{}{"id": 5.0, "name": "Medor"}
+	if violations := schema.Validate(response); len(violations) != 0 {
+		t.Fatalf("expect no violation on response, got %v", violations)
+	}
+
+	if violations := schema.Validate(request); len(violations) != 1 || violations[0].String() != "# (required): missing property id" {
+		t.Fatalf("expect missing id on response, got %v", violations)
+	}
+}
+
+func TestParseUnresolvedSchemaRef(t *testing.T) {
+	spec := `
+info:
+  title: unresolved
+paths:
+  /pet:
+    get:
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Pet'
+`
+
+	_, err := alitest.ParseString(spec)
+
+	if err == nil || err.Error() != "cannot resolve schema reference #/components/schemas/Pet" {
+		t.Fatalf("expect unresolved reference error, got %v", err)
+	}
+}
+
+func TestParseInvalidSchemaPattern(t *testing.T) {
+	spec := `
+info:
+  title: invalid pattern
+paths:
+  /pet:
+    get:
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                type: string
+                pattern: '[a-z'
+`
+
+	_, err := alitest.ParseString(spec)
+
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid pattern [a-z : ") {
+		t.Fatalf("expect invalid pattern error, got %v", err)
+	}
+}
+
+func TestParseOpenAPI31ExclusiveBounds(t *testing.T) {
+	spec := `
+openapi: 3.1.0
+info:
+  title: exclusive bounds
+paths:
+  /pet/age:
+    get:
+      responses:
+        200:
+          x-ali-response:
+            expected: 5
+          content:
+            application/json:
+              schema:
+                type: [integer, 'null']
+                exclusiveMinimum: 0
+                exclusiveMaximum: 30
+`
+
+	integrationSuite, err := alitest.ParseString(spec)
+
+	if err != nil {
+		t.Fatalf("expect nil error, got %v", err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`5`))
+	})
+
+	report := integrationSuite.Run(t, alitest.RunParameters{Handler: handler})
+
+	if report.Totals.Passed != 1 {
+		t.Fatalf("expect 1 passed response, got %+v", report.Totals)
+	}
+}
+
+func TestParseSchemaRefs(t *testing.T) {
+	spec := `
+info:
+  title: deep references
+paths:
+  /pet:
+    get:
+      responses:
+        200:
+          x-ali-response:
+            expected:
+              $ref: '#/components/headers/RateLimit'
+          headers:
+            X-Rate-Limit:
+              $ref: '#/components/headers/RateLimit'
+  /pet/name:
+    get:
+      responses:
+        200:
+          x-ali-response:
+            expected: Medor
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Pet/properties/name'
+components:
+  headers:
+    RateLimit:
+      required: true
+      schema:
+        $ref: '#/components/schemas/Limit'
+  schemas:
+    Limit:
+      type: integer
+    Pet:
+      type: object
+      properties:
+        name:
+          type: string
+          pattern: '^[A-Z]'
+`
+
+	integrationSuite, err := alitest.ParseString(spec)
+
+	if err != nil {
+		t.Fatalf("expect nil error, got %v", err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Rate-Limit", "10")
+		if r.URL.Path == "/pet/name" {
+			_, _ = w.Write([]byte(`"Medor"`))
+			return
+		}
+		// the $ref of the test data is a payload, not a reference
+		_, _ = w.Write([]byte(`{"$ref": "#/components/headers/RateLimit"}`))
+	})
+
+	report := integrationSuite.Run(t, alitest.RunParameters{Handler: handler})
+
+	if report.Totals.Passed != 2 {
+		t.Fatalf("expect 2 passed responses, got %+v", report.Totals)
+	}
+}
+
+func TestParseUnresolvedDeepSchemaRef(t *testing.T) {
+	spec := `
+info:
+  title: unresolved
+paths:
+  /pet:
+    get:
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Pet/properties/age'
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        name:
+          type: string
+`
+
+	_, err := alitest.ParseString(spec)
+
+	if err == nil || err.Error() != "cannot resolve schema reference #/components/schemas/Pet/properties/age" {
+		t.Fatalf("expect unresolved reference error, got %v", err)
+	}
+}
@@ -0,0 +1,380 @@
+package alitest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// invalidCredential is sent to check that a secured operation rejects unknown credentials.
+const invalidCredential = "alitest-invalid-credential"
+
+// errNoCredentials is returned when RunParameters.Credentials satisfies none of the security requirements.
+var errNoCredentials = errors.New("no credentials")
+
+// Security scheme types.
+const (
+	ApiKeySecurity        = "apiKey"
+	HttpSecurity          = "http"
+	OAuth2Security        = "oauth2"
+	OpenIdConnectSecurity = "openIdConnect"
+)
+
+type OpenApiSecurityScheme struct {
+	Type        string `json:"type" yaml:"type"`
+	Description string `json:"description" yaml:"description"`
+	// Name and In locate the key of an apiKey scheme
+	Name string            `json:"name" yaml:"name"`
+	In   ParameterLocation `json:"in" yaml:"in"`
+	// Scheme is the http authorization scheme, basic or bearer
+	Scheme       string            `json:"scheme" yaml:"scheme"`
+	BearerFormat string            `json:"bearerFormat" yaml:"bearerFormat"`
+	Flows        OpenApiOAuthFlows `json:"flows" yaml:"flows"`
+}
+
+type OpenApiOAuthFlows struct {
+	ClientCredentials *OpenApiOAuthFlow `json:"clientCredentials" yaml:"clientCredentials"`
+}
+
+type OpenApiOAuthFlow struct {
+	TokenURL string            `json:"tokenUrl" yaml:"tokenUrl"`
+	Scopes   map[string]string `json:"scopes" yaml:"scopes"`
+}
+
+// OpenApiSecurityRequirement maps a security scheme name to the required scopes.
+// All the schemes of a requirement must be satisfied.
+type OpenApiSecurityRequirement map[string][]string
+
+// Credential holds the secrets used to satisfy a security scheme.
+// Only the fields related to the scheme type are used.
+type Credential struct {
+	// Username and Password are used by the http basic scheme
+	Username string
+	Password string
+	// Token is sent by the http bearer, oauth2 and openIdConnect schemes.
+	// For oauth2, it is fetched with the client credentials flow when empty.
+	Token string
+	// APIKey is the value of an apiKey scheme
+	APIKey string
+	// ClientID and ClientSecret are used by the oauth2 client credentials flow
+	ClientID     string
+	ClientSecret string
+	// TokenURL overrides the tokenUrl of the oauth2 client credentials flow
+	TokenURL string
+}
+
+// authenticate attaches to the request the credentials of the first satisfiable requirement.
+// An empty requirement makes the authentication optional: it is only used when no other
+// requirement is satisfiable, so that the configured credentials are sent.
+func (r *suiteRun) authenticate(request *http.Request, requirements []OpenApiSecurityRequirement) error {
+	for _, requirement := range requirements {
+		if len(requirement) == 0 || !r.satisfies(requirement) {
+			continue
+		}
+		for _, name := range requirement.schemeNames() {
+			if err := r.applyCredential(request, name, requirement[name]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if !requiresAuthentication(requirements) {
+		return nil
+	}
+
+	var alternatives []string
+	for _, requirement := range requirements {
+		alternatives = append(alternatives, strings.Join(requirement.schemeNames(), " and "))
+	}
+	return fmt.Errorf("%w for the security requirements (%s), set them in RunParameters.Credentials", errNoCredentials, strings.Join(alternatives, " or "))
+}
+
+func (r *suiteRun) satisfies(requirement OpenApiSecurityRequirement) bool {
+	for name := range requirement {
+		if _, found := r.parameters.Credentials[name]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *suiteRun) applyCredential(request *http.Request, name string, scopes []string) error {
+	scheme, found := r.securitySchemes[name]
+	if !found {
+		return fmt.Errorf("unknown security scheme %s", name)
+	}
+	credential := r.parameters.Credentials[name]
+
+	switch scheme.Type {
+	case ApiKeySecurity:
+		if !scheme.applyAPIKey(request, credential.APIKey) {
+			return fmt.Errorf("unsupported apiKey location %s for security scheme %s", scheme.In, name)
+		}
+	case HttpSecurity:
+		switch strings.ToLower(scheme.Scheme) {
+		case "basic":
+			request.SetBasicAuth(credential.Username, credential.Password)
+		case "bearer":
+			request.Header.Set("Authorization", "Bearer "+credential.Token)
+		default:
+			return fmt.Errorf("unsupported http scheme %s for security scheme %s", scheme.Scheme, name)
+		}
+	case OAuth2Security, OpenIdConnectSecurity:
+		token, err := r.oauth2Token(name, scheme, credential, scopes)
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	default:
+		return fmt.Errorf("unsupported security scheme type %s for %s", scheme.Type, name)
+	}
+	return nil
+}
+
+// applyAPIKey sets the key of an apiKey scheme, it returns false for an unsupported location.
+func (s OpenApiSecurityScheme) applyAPIKey(request *http.Request, key string) bool {
+	switch s.In {
+	case Header:
+		request.Header.Set(s.Name, key)
+	case Query:
+		// appended as is, re-encoding the query would break the styled parameters
+		pair := url.QueryEscape(s.Name) + "=" + url.QueryEscape(key)
+		if request.URL.RawQuery != "" {
+			pair = "&" + pair
+		}
+		request.URL.RawQuery += pair
+	case Cookie:
+		request.AddCookie(&http.Cookie{Name: s.Name, Value: key})
+	default:
+		return false
+	}
+	return true
+}
+
+// oauth2Token returns the token of the credential, or fetches one with the client credentials flow.
+// Fetched tokens are reused for the whole suite run.
+func (r *suiteRun) oauth2Token(name string, scheme OpenApiSecurityScheme, credential Credential, scopes []string) (string, error) {
+	if credential.Token != "" {
+		return credential.Token, nil
+	}
+
+	cacheKey := name + " " + strings.Join(scopes, " ")
+	if token, found := r.tokens[cacheKey]; found {
+		return token, nil
+	}
+
+	tokenURL := credential.TokenURL
+	if tokenURL == "" && scheme.Flows.ClientCredentials != nil {
+		tokenURL = scheme.Flows.ClientCredentials.TokenURL
+	}
+	if tokenURL == "" {
+		return "", fmt.Errorf("no token url for the client credentials flow of security scheme %s", name)
+	}
+
+	// a relative token url is relative to the tested server
+	if parsed, err := url.Parse(tokenURL); err == nil && !parsed.IsAbs() {
+		if tokenURL, err = r.url(tokenURL); err != nil {
+			return "", fmt.Errorf("cannot resolve the token url of security scheme %s : %w", name, err)
+		}
+	}
+
+	token, err := r.fetchClientCredentialsToken(tokenURL, credential, scopes)
+	if err != nil {
+		return "", fmt.Errorf("cannot get a token for security scheme %s : %w", name, err)
+	}
+
+	r.tokens[cacheKey] = token
+	return token, nil
+}
+
+func (r *suiteRun) fetchClientCredentialsToken(tokenURL string, credential Credential, scopes []string) (string, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(scopes) > 0 {
+		form.Set("scope", strings.Join(scopes, " "))
+	}
+
+	request, err := http.NewRequest(http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(credential.ClientID), url.QueryEscape(credential.ClientSecret))
+
+	response, err := r.do(request)
+	if err != nil {
+		return "", err
+	}
+	defer closeBody(response)
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint %s answered status %d", tokenURL, response.StatusCode)
+	}
+
+	var tokenResponse struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&tokenResponse); err != nil {
+		return "", err
+	}
+	if tokenResponse.AccessToken == "" {
+		return "", fmt.Errorf("token endpoint %s returned no access_token", tokenURL)
+	}
+	return tokenResponse.AccessToken, nil
+}
+
+// runSecurityTests checks that a secured operation rejects requests without credentials,
+// or with invalid ones.
+func (o OpenApiOperation) runSecurityTests(t *testing.T, ctx operationRunContext) {
+	if !requiresAuthentication(ctx.security) {
+		return
+	}
+
+	sample, statusCode, found := o.Responses.requestSample(ctx)
+
+	if !found {
+		t.Run("unauthenticated", func(t *testing.T) {
+			t.Skipf("Skipped, no test data of %s builds a complete request", ctx.operationID)
+		})
+		return
+	}
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		sample.runRejectedTest(t, ctx, statusCode, func(request *http.Request) error { return nil })
+	})
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		sample.runRejectedTest(t, ctx, statusCode, func(request *http.Request) error {
+			return ctx.run.authenticateInvalid(request, ctx.security)
+		})
+	})
+}
+
+// runRejectedTest sends the request after the given authentication, and expects it to be rejected.
+// The operation hooks run around it, as for the test data of the status code it was built from.
+func (o OpenApiResponse) runRejectedTest(t *testing.T, ctx operationRunContext, statusCode string, authenticate func(*http.Request) error) {
+	o.runOperationHooks(t, ctx, statusCode)
+
+	request, err := o.newRequest(ctx)
+
+	if err != nil {
+		t.Fatalf("Got unexpected error (%v) when building a %s on %s", err, ctx.verb, ctx.url)
+	}
+
+	if err := authenticate(request); err != nil {
+		t.Fatalf("Cannot authenticate the %s on %s : %v", ctx.verb, request.URL, err)
+	}
+
+	response, err := ctx.run.do(request)
+
+	if err != nil {
+		t.Fatalf("Got unexpected error (%v) when performing a %s on %s", err, ctx.verb, request.URL)
+	}
+	defer closeBody(response)
+
+	if response.StatusCode != http.StatusUnauthorized && response.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expect status 401 or 403 for the secured %s, but got status %d", ctx.operationID, response.StatusCode)
+	}
+}
+
+// requestSample returns the first response or case test data able to build a complete request,
+// interpolated with the captured variables, and its status code. The skipped responses are left out.
+func (r OpenApiResponses) requestSample(ctx operationRunContext) (OpenApiResponse, string, bool) {
+	for _, statusCode := range r.StatusCodes() {
+		response := r[statusCode]
+		if response.AliSkip != "" {
+			continue
+		}
+		candidates := []OpenApiResponse{*response}
+		if len(response.AliCases) > 0 {
+			candidates = candidates[:0]
+			for _, aliCase := range response.AliCases {
+				candidates = append(candidates, response.WithCase(aliCase))
+			}
+		}
+
+		for _, candidate := range candidates {
+			sample, err := candidate.Interpolate(ctx.run.variables)
+			if err != nil {
+				continue
+			}
+			bodyMissing := sample.AliBody == nil && ctx.requestBody != nil && ctx.requestBody.Required
+			if len(sample.MissingParameters(ctx.parameters)) == 0 && !bodyMissing && !sample.omitsData() {
+				return sample, statusCode, true
+			}
+		}
+	}
+	return OpenApiResponse{}, "", false
+}
+
+// omitsData tells if the test data deliberately leaves a parameter or the body out of the request.
+func (o OpenApiResponse) omitsData() bool {
+	for _, parameter := range o.AliParameters {
+		if parameter.Omit {
+			return true
+		}
+	}
+	return o.AliOmitBody
+}
+
+// requiresAuthentication tells if credentials are mandatory,
+// an empty requirement makes them optional.
+func requiresAuthentication(requirements []OpenApiSecurityRequirement) bool {
+	for _, requirement := range requirements {
+		if len(requirement) == 0 {
+			return false
+		}
+	}
+	return len(requirements) > 0
+}
+
+// authenticateInvalid attaches deliberately invalid credentials for the first requirement
+// made of known schemes.
+func (r *suiteRun) authenticateInvalid(request *http.Request, requirements []OpenApiSecurityRequirement) error {
+	for _, requirement := range requirements {
+		if !r.knowsSchemes(requirement) {
+			continue
+		}
+		for _, name := range requirement.schemeNames() {
+			scheme := r.securitySchemes[name]
+			switch scheme.Type {
+			case ApiKeySecurity:
+				scheme.applyAPIKey(request, invalidCredential)
+			case HttpSecurity:
+				if strings.EqualFold(scheme.Scheme, "basic") {
+					request.SetBasicAuth(invalidCredential, invalidCredential)
+				} else {
+					request.Header.Set("Authorization", "Bearer "+invalidCredential)
+				}
+			default:
+				request.Header.Set("Authorization", "Bearer "+invalidCredential)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("no known security scheme in the security requirements")
+}
+
+func (r *suiteRun) knowsSchemes(requirement OpenApiSecurityRequirement) bool {
+	for name := range requirement {
+		if _, found := r.securitySchemes[name]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (r OpenApiSecurityRequirement) schemeNames() []string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
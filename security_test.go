@@ -0,0 +1,329 @@
+package alitest_test
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/toolzup/alitest"
+)
+
+//go:embed dataset/security_specification.yaml
+var securitySpec string
+
+func TestRunSecurity(t *testing.T) {
+	var tokenRequests int
+	calledPaths := map[string]bool{}
+	rejectedPaths := map[string]int{}
+
+	integrationSuite, err := alitest.ParseString(securitySpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorized := false
+
+		switch r.URL.Path {
+		case "/oauth/token":
+			clientID, clientSecret, _ := r.BasicAuth()
+			if r.Method != http.MethodPost || clientID != "some-client" || clientSecret != "some-secret" ||
+				r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "orders:read" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			tokenRequests++
+			if err := json.NewEncoder(w).Encode(map[string]string{"access_token": "some-access-token", "token_type": "Bearer"}); err != nil {
+				t.Errorf("expect nil error, but got %v", err)
+			}
+			return
+		case "/pets":
+			authorized = r.Header.Get("Authorization") == "Bearer some-jwt"
+		case "/pets/public":
+			authorized = r.Header.Get("Authorization") == ""
+		case "/pets/optional":
+			authorized = r.Header.Get("Authorization") == "Bearer some-jwt"
+		case "/admin":
+			username, password, _ := r.BasicAuth()
+			authorized = username == "admin" && password == "secret"
+		case "/keys/header":
+			authorized = r.Header.Get("X-API-Key") == "some-key"
+		case "/keys/query":
+			// the key is appended to the styled query, left as is
+			authorized = r.URL.RawQuery == "tags=dog|brown&api_key=some-query-key"
+		case "/keys/cookie":
+			cookie, err := r.Cookie("api_session")
+			authorized = err == nil && cookie.Value == "some-session" && r.Header.Get("X-API-Key") == "some-key"
+		case "/orders":
+			authorized = r.Header.Get("Authorization") == "Bearer some-access-token"
+		}
+
+		calledPaths[r.URL.Path] = true
+
+		if !authorized {
+			rejectedPaths[r.URL.Path]++
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	integrationSuite.Run(t, alitest.RunParameters{
+		URL: srv.URL,
+		Credentials: map[string]alitest.Credential{
+			"bearerAuth": {Token: "some-jwt"},
+			"basicAuth":  {Username: "admin", Password: "secret"},
+			"headerKey":  {APIKey: "some-key"},
+			"queryKey":   {APIKey: "some-query-key"},
+			"cookieKey":  {APIKey: "some-session"},
+			"oauth":      {ClientID: "some-client", ClientSecret: "some-secret"},
+		},
+	})
+
+	for _, path := range []string{"/pets", "/pets/public", "/pets/optional", "/admin", "/keys/header", "/keys/query", "/keys/cookie", "/orders"} {
+		if !calledPaths[path] {
+			t.Errorf("%s not covered", path)
+		}
+	}
+
+	// the unauthenticated and invalid credentials checks are rejected, for each secured operation
+	for _, path := range []string{"/pets", "/admin", "/keys/header", "/keys/query", "/keys/cookie", "/orders"} {
+		if rejectedPaths[path] != 2 {
+			t.Errorf("expect 2 rejected requests on %s, but got %d", path, rejectedPaths[path])
+		}
+	}
+
+	for _, path := range []string{"/pets/public", "/pets/optional"} {
+		if rejectedPaths[path] != 0 {
+			t.Errorf("expect no rejected request on %s, but got %d", path, rejectedPaths[path])
+		}
+	}
+
+	if tokenRequests != 1 {
+		t.Errorf("expect 1 token request, but got %d", tokenRequests)
+	}
+}
+
+func TestRunSecurityWithoutCredentials(t *testing.T) {
+	integrationSuite, err := alitest.ParseString(securitySpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pets/public" && r.URL.Path != "/pets/optional" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+
+	report := integrationSuite.Run(t, alitest.RunParameters{Handler: handler})
+
+	for _, entry := range report.Entries {
+		expected := alitest.Skipped
+		if entry.Path == "/pets/public" || entry.Path == "/pets/optional" {
+			expected = alitest.NoTestData
+		}
+		if entry.Status != expected {
+			t.Errorf("expect %s %s to be %s, but got %s", entry.Method, entry.Path, expected, entry.Status)
+		}
+	}
+}
+
+//go:embed dataset/security_sample_specification.yaml
+var securitySampleSpec string
+
+func TestRunSecuritySample(t *testing.T) {
+	rejectedPaths := map[string]int{}
+
+	integrationSuite, err := alitest.ParseString(securitySampleSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the pet is looked up before the credentials are checked, as many servers do
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/pets/":
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		case r.URL.Path != "/pets" && r.URL.Path != "/pets/12345678":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case r.Header.Get("Authorization") != "Bearer some-jwt":
+			rejectedPaths[r.URL.Path]++
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		case r.Method == http.MethodPost:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id": 12345678}`))
+		}
+	})
+
+	integrationSuite.Run(t, alitest.RunParameters{
+		Handler:     handler,
+		Credentials: map[string]alitest.Credential{"bearerAuth": {Token: "some-jwt"}},
+	})
+
+	for _, path := range []string{"/pets", "/pets/12345678"} {
+		if rejectedPaths[path] != 2 {
+			t.Errorf("expect 2 rejected requests on %s, but got %d", path, rejectedPaths[path])
+		}
+	}
+}
+
+// TestRunSecurityHooks ensures the rejected requests run between the operation hooks, as the other tests.
+func TestRunSecurityHooks(t *testing.T) {
+	var events []string
+
+	integrationSuite, err := alitest.ParseString(securitySampleSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the pet exists only while the getPet hooks have seeded it
+	seeded := false
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pets" {
+			if r.Header.Get("Authorization") != "Bearer some-jwt" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id": 12345678}`))
+			return
+		}
+
+		status := http.StatusOK
+		switch {
+		case r.URL.Path == "/pets/":
+			status = http.StatusBadRequest
+		case !seeded:
+			status = http.StatusNotFound
+		case r.Header.Get("Authorization") != "Bearer some-jwt":
+			status = http.StatusUnauthorized
+		}
+		events = append(events, fmt.Sprintf("request %s %d", r.URL.Path, status))
+		w.WriteHeader(status)
+	})
+
+	operationHook := func(name string, state bool) alitest.OperationHook {
+		return func(ctx context.Context, parameters alitest.OperationHookParameters) error {
+			seeded = state
+			events = append(events, fmt.Sprintf("%s %s %s", name, parameters.OperationID, parameters.StatusCode))
+			return nil
+		}
+	}
+
+	integrationSuite.Run(t, alitest.RunParameters{
+		Handler:         handler,
+		Credentials:     map[string]alitest.Credential{"bearerAuth": {Token: "some-jwt"}},
+		BeforeOperation: map[string]alitest.OperationHook{"getPet": operationHook("before", true)},
+		AfterOperation:  map[string]alitest.OperationHook{"getPet": operationHook("after", false)},
+	})
+
+	expectedEvents := []string{
+		"before getPet 200", "request /pets/12345678 200", "after getPet 200",
+		"before getPet 400", "request /pets/ 400", "after getPet 400",
+		"before getPet 200", "request /pets/12345678 401", "after getPet 200",
+		"before getPet 200", "request /pets/12345678 401", "after getPet 200",
+	}
+
+	if strings.Join(events, "\n") != strings.Join(expectedEvents, "\n") {
+		t.Fatalf("expect events %v, but got %v", expectedEvents, events)
+	}
+}
+
+// TestRunSecuritySkippedSample ensures no rejected request is built from a skipped response.
+func TestRunSecuritySkippedSample(t *testing.T) {
+	var calls []string
+
+	integrationSuite, err := alitest.ParseString(`
+openapi: 3.0.1
+info:
+  title: skipped sample
+security:
+- bearerAuth: []
+paths:
+  /pets/{petId}:
+    delete:
+      operationId: deletePet
+      parameters:
+        - name: petId
+          in: path
+          required: true
+      responses:
+        204:
+          description: deleted
+          x-ali-skip: deletes the production pet
+          x-ali-parameters:
+            petId:
+              value: medor
+components:
+  securitySchemes:
+    bearerAuth:
+      type: http
+      scheme: bearer
+`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	integrationSuite.Run(t, alitest.RunParameters{
+		Handler:     handler,
+		Credentials: map[string]alitest.Credential{"bearerAuth": {Token: "some-jwt"}},
+	})
+
+	if len(calls) != 0 {
+		t.Fatalf("expect no request, but got %v", calls)
+	}
+}
+
+func TestRunSecurityUnprotected(t *testing.T) {
+	output := runFailingTest(t, func(t *testing.T) {
+		integrationSuite, err := alitest.ParseString(securitySampleSpec)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the credentials are never checked
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/pets":
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"id": 12345678}`))
+			case "/pets/":
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		})
+
+		integrationSuite.Run(t, alitest.RunParameters{
+			Handler:     handler,
+			Credentials: map[string]alitest.Credential{"bearerAuth": {Token: "some-jwt"}},
+		})
+	})
+
+	for _, operationID := range []string{"addPet", "getPet"} {
+		expected := "Expect status 401 or 403 for the secured " + operationID + ", but got status"
+		if !strings.Contains(output, expected) {
+			t.Errorf("expect %q in the output, but got :\n%s", expected, output)
+		}
+	}
+}
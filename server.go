@@ -0,0 +1,148 @@
+package alitest
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// handlerBaseURL is the base url of a relative server, when the requests are served by RunParameters.Handler.
+const handlerBaseURL = "http://localhost"
+
+// serverVariablePattern matches a {name} server variable.
+var serverVariablePattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+type OpenApiServer struct {
+	URL         string                           `json:"url" yaml:"url"`
+	Description string                           `json:"description" yaml:"description"`
+	Variables   map[string]OpenApiServerVariable `json:"variables" yaml:"variables"`
+}
+
+type OpenApiServerVariable struct {
+	Enum        []string `json:"enum" yaml:"enum"`
+	Default     string   `json:"default" yaml:"default"`
+	Description string   `json:"description" yaml:"description"`
+}
+
+// ResolveURL substitutes the variables of the server url, with the given values or their defaults.
+func (s OpenApiServer) ResolveURL(values map[string]string) (string, error) {
+	var err error
+
+	resolved := serverVariablePattern.ReplaceAllStringFunc(s.URL, func(reference string) string {
+		name := reference[1 : len(reference)-1]
+		variable, found := s.Variables[name]
+		if !found {
+			if err == nil {
+				err = fmt.Errorf("server variable %s of %s is not declared", name, s.URL)
+			}
+			return reference
+		}
+
+		value, overridden := values[name]
+		if !overridden {
+			value = variable.Default
+		}
+
+		if len(variable.Enum) > 0 && !slices.Contains(variable.Enum, value) {
+			if err == nil {
+				err = fmt.Errorf("value %q of server variable %s is not one of %v", value, name, variable.Enum)
+			}
+		}
+		return value
+	})
+
+	return resolved, err
+}
+
+// selectServer picks the server among the ones of the document, by description or by index.
+// Without any declared server, the server is the root of RunParameters.URL, and no server
+// can be selected.
+func (p RunParameters) selectServer(servers []OpenApiServer) (OpenApiServer, error) {
+	if len(servers) == 0 && p.ServerDescription == "" && p.ServerIndex == 0 {
+		return OpenApiServer{URL: "/"}, nil
+	}
+
+	if p.ServerDescription != "" {
+		for _, server := range servers {
+			if server.Description == p.ServerDescription {
+				return server, nil
+			}
+		}
+		return OpenApiServer{}, fmt.Errorf("no server described as %q", p.ServerDescription)
+	}
+
+	if p.ServerIndex < 0 || p.ServerIndex >= len(servers) {
+		return OpenApiServer{}, fmt.Errorf("no server at index %d, %d servers are declared", p.ServerIndex, len(servers))
+	}
+	return servers[p.ServerIndex], nil
+}
+
+// selectOverride picks the server among the ones overriding the document servers, by description
+// or by index as the document one, and falls back to the first server when none matches.
+func (p RunParameters) selectOverride(servers []OpenApiServer) OpenApiServer {
+	if p.ServerDescription != "" {
+		for _, server := range servers {
+			if server.Description == p.ServerDescription {
+				return server
+			}
+		}
+		return servers[0]
+	}
+
+	if p.ServerIndex < 0 || p.ServerIndex >= len(servers) {
+		return servers[0]
+	}
+	return servers[p.ServerIndex]
+}
+
+// url returns the url of a path of the tested server. The servers of the operation override
+// the ones of the path, which override the ones of the document.
+// RunParameters.URL replaces an absolute server url, and is the base of a relative one.
+func (r *suiteRun) url(path string, servers ...[]OpenApiServer) (string, error) {
+	server, err := r.parameters.selectServer(r.servers)
+	if err != nil {
+		return "", err
+	}
+
+	for _, levelServers := range servers {
+		if len(levelServers) > 0 {
+			server = r.parameters.selectOverride(levelServers)
+		}
+	}
+
+	serverURL, resolveErr := server.ResolveURL(r.parameters.ServerVariables)
+	parsed, err := url.Parse(serverURL)
+
+	// the variables of a replaced server are not used, they are not checked either
+	if r.parameters.URL != "" && err == nil && parsed.IsAbs() {
+		return joinURL(r.parameters.URL, path), nil
+	}
+
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid server url %s : %w", serverURL, err)
+	}
+
+	switch {
+	case r.parameters.URL != "":
+		return joinURL(joinURL(r.parameters.URL, serverURL), path), nil
+	case parsed.IsAbs():
+		return joinURL(serverURL, path), nil
+	case r.parameters.Handler != nil:
+		return joinURL(joinURL(handlerBaseURL, serverURL), path), nil
+	}
+	return "", fmt.Errorf("the server url %s is relative, set RunParameters.URL", serverURL)
+}
+
+// joinURL appends a path to a base url, with exactly one "/" between them.
+// The "/" path keeps the trailing slash of the base.
+func joinURL(base, path string) string {
+	if path == "" {
+		return base
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(path, "/")
+}
@@ -0,0 +1,266 @@
+package alitest_test
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/toolzup/alitest"
+)
+
+//go:embed dataset/servers_specification.yaml
+var serversSpec string
+
+//go:embed dataset/servers_override_specification.yaml
+var serversOverrideSpec string
+
+func TestServerResolveURL(t *testing.T) {
+	server := alitest.OpenApiServer{
+		URL: "https://{region}.example.com/{version}",
+		Variables: map[string]alitest.OpenApiServerVariable{
+			"region":  {Default: "eu", Enum: []string{"eu", "us"}},
+			"version": {Default: "v1"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		server   alitest.OpenApiServer
+		values   map[string]string
+		expected string
+		err      string
+	}{
+		{
+			name:     "defaults",
+			server:   server,
+			expected: "https://eu.example.com/v1",
+		},
+		{
+			name:     "overridden values",
+			server:   server,
+			values:   map[string]string{"region": "us", "version": "v2"},
+			expected: "https://us.example.com/v2",
+		},
+		{
+			name:   "value out of the enum",
+			server: server,
+			values: map[string]string{"region": "asia"},
+			err:    `value "asia" of server variable region is not one of [eu us]`,
+		},
+		{
+			name:   "undeclared variable",
+			server: alitest.OpenApiServer{URL: "https://{region}.example.com"},
+			err:    "server variable region of https://{region}.example.com is not declared",
+		},
+		{
+			name:     "no variable",
+			server:   alitest.OpenApiServer{URL: "/api"},
+			expected: "/api",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolved, err := test.server.ResolveURL(test.values)
+
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expect error %q, got %v", test.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expect nil error, got %v", err)
+			}
+
+			if resolved != test.expected {
+				t.Fatalf("expect %s, got %s", test.expected, resolved)
+			}
+		})
+	}
+}
+
+func TestRunServers(t *testing.T) {
+	integrationSuite, err := alitest.ParseString(serversSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		parameters alitest.RunParameters
+		expected   []string
+	}{
+		{
+			name:       "first server",
+			parameters: alitest.RunParameters{},
+			expected:   []string{"GET owners.example.com/owners", "POST legacy.example.com/api/owners", "GET eu.example.com/v1/pets"},
+		},
+		{
+			name:       "server variables",
+			parameters: alitest.RunParameters{ServerVariables: map[string]string{"region": "us"}},
+			expected:   []string{"GET owners.example.com/owners", "POST legacy.example.com/api/owners", "GET us.example.com/v1/pets"},
+		},
+		{
+			name:       "server by description",
+			parameters: alitest.RunParameters{ServerDescription: "local", ServerVariables: map[string]string{"version": "v2"}},
+			expected:   []string{"GET localhost/owners-api/owners", "POST localhost/legacy/owners", "GET localhost/v2/pets"},
+		},
+		{
+			name:       "url replacing the absolute servers",
+			parameters: alitest.RunParameters{URL: "http://127.0.0.1:8080/base/"},
+			expected:   []string{"GET 127.0.0.1:8080/base/owners", "POST 127.0.0.1:8080/base/owners", "GET 127.0.0.1:8080/base/pets"},
+		},
+		{
+			name:       "url as the base of the relative servers",
+			parameters: alitest.RunParameters{URL: "http://127.0.0.1:8080/base/", ServerIndex: 1},
+			expected:   []string{"GET 127.0.0.1:8080/base/owners-api/owners", "POST 127.0.0.1:8080/base/legacy/owners", "GET 127.0.0.1:8080/base/v1/pets"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls []string
+
+			test.parameters.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, r.Method+" "+r.Host+r.RequestURI)
+				if r.Method == http.MethodPost {
+					w.WriteHeader(http.StatusCreated)
+				}
+			})
+
+			integrationSuite.Run(t, test.parameters)
+
+			if strings.Join(calls, ",") != strings.Join(test.expected, ",") {
+				t.Fatalf("expect calls %v, but got %v", test.expected, calls)
+			}
+		})
+	}
+}
+
+func TestRunServerOverrides(t *testing.T) {
+	integrationSuite, err := alitest.ParseString(serversOverrideSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		parameters alitest.RunParameters
+		expected   []string
+	}{
+		{
+			name:       "first server",
+			parameters: alitest.RunParameters{},
+			expected:   []string{"GET owners.example.com/owners", "GET pets.example.com/pets", "GET eu.example.com/toys"},
+		},
+		{
+			name:       "description of a document server only",
+			parameters: alitest.RunParameters{ServerDescription: "local"},
+			expected:   []string{"GET owners.example.com/owners", "GET pets.example.com/pets", "GET localhost/toys"},
+		},
+		{
+			name:       "index out of an override list",
+			parameters: alitest.RunParameters{ServerIndex: 1},
+			expected:   []string{"GET owners-sandbox.example.com/owners", "GET pets.example.com/pets", "GET localhost/toys"},
+		},
+		{
+			name:       "url replacing a server with an invalid variable",
+			parameters: alitest.RunParameters{URL: "http://127.0.0.1:8080", ServerVariables: map[string]string{"region": "asia"}},
+			expected:   []string{"GET 127.0.0.1:8080/owners", "GET 127.0.0.1:8080/pets", "GET 127.0.0.1:8080/toys"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls []string
+
+			test.parameters.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, r.Method+" "+r.Host+r.RequestURI)
+			})
+
+			integrationSuite.Run(t, test.parameters)
+
+			if strings.Join(calls, ",") != strings.Join(test.expected, ",") {
+				t.Fatalf("expect calls %v, but got %v", test.expected, calls)
+			}
+		})
+	}
+}
+
+func TestRunServerRootPath(t *testing.T) {
+	integrationSuite, err := alitest.ParseString(`
+openapi: 3.0.1
+info:
+  title: root path
+servers:
+  - url: /v1
+paths:
+  /:
+    get:
+      operationId: getRoot
+      responses:
+        200:
+          description: successful operation
+`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.Host+r.RequestURI)
+	})
+
+	integrationSuite.Run(t, alitest.RunParameters{Handler: handler})
+
+	if strings.Join(calls, ",") != "GET localhost/v1/" {
+		t.Fatalf("expect the trailing slash of the root path, but got %v", calls)
+	}
+}
+
+func TestRunServerSelectionWithoutServers(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters alitest.RunParameters
+		err        string
+	}{
+		{
+			name:       "server by description",
+			parameters: alitest.RunParameters{ServerDescription: "local"},
+			err:        `no server described as "local"`,
+		},
+		{
+			name:       "server by index",
+			parameters: alitest.RunParameters{ServerIndex: 1},
+			err:        "no server at index 1, 0 servers are declared",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output := runFailingTest(t, func(t *testing.T) {
+				integrationSuite, err := alitest.ParseString(skipSpec)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				test.parameters.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`[]`))
+				})
+
+				integrationSuite.Run(t, test.parameters)
+			})
+
+			if !strings.Contains(output, test.err) {
+				t.Fatalf("expect %q in the output, but got :\n%s", test.err, output)
+			}
+		})
+	}
+}
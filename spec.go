@@ -3,13 +3,15 @@ package alitest
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
-	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
-	"time"
 
 	diff "github.com/nsf/jsondiff"
 	"gopkg.in/yaml.v3"
@@ -27,9 +29,14 @@ const (
 )
 
 type OpenApiDocument struct {
-	Info       ApiInfo                `json:"info" yaml:"info"`
-	Paths      map[string]OpenApiPath `json:"paths" yaml:"paths"`
-	Components ApiComponents          `json:"components" yaml:"components"`
+	Info       ApiInfo                      `json:"info" yaml:"info"`
+	Paths      map[string]OpenApiPath       `json:"paths" yaml:"paths"`
+	Components ApiComponents                `json:"components" yaml:"components"`
+	Security   []OpenApiSecurityRequirement `json:"security" yaml:"security"`
+	Servers    []OpenApiServer              `json:"servers" yaml:"servers"`
+
+	// externalSchemas are the schemas referenced in other files, by their file qualified reference
+	externalSchemas map[string]*Schema
 }
 
 type ApiInfo struct {
@@ -42,8 +49,13 @@ type ApiInfo struct {
 }
 
 type ApiComponents struct {
-	Schemas map[string]interface{} `json:"schemas" yaml:"schemas"`
-	// TODO implements responses, parameters, examples, requestBodies, headers, securitySchemes, links, callbacks, pathItems
+	Schemas         map[string]*Schema               `json:"schemas" yaml:"schemas"`
+	Responses       map[string]*OpenApiResponse      `json:"responses" yaml:"responses"`
+	Parameters      map[string]OpenApiParameter      `json:"parameters" yaml:"parameters"`
+	RequestBodies   map[string]*OpenApiRequestBody   `json:"requestBodies" yaml:"requestBodies"`
+	Headers         map[string]OpenApiHeader         `json:"headers" yaml:"headers"`
+	SecuritySchemes map[string]OpenApiSecurityScheme `json:"securitySchemes" yaml:"securitySchemes"`
+	// TODO implements examples, links, callbacks, pathItems
 }
 
 type OpenApiPath struct {
@@ -57,107 +69,193 @@ type OpenApiPath struct {
 	Head        *OpenApiOperation `json:"head" yaml:"head"`
 	Patch       *OpenApiOperation `json:"patch" yaml:"patch"`
 	Trace       *OpenApiOperation `json:"trace" yaml:"trace"`
-	// TODO test put, post, delete, options, head, patch, trace, servers, $ref
+	Servers     []OpenApiServer   `json:"servers" yaml:"servers"`
+	// Parameters are shared by all the operations of the path, which can override them
+	Parameters []OpenApiParameter `json:"parameters" yaml:"parameters"`
 }
 
-func (p OpenApiPath) CountOperations() int {
-	var count int
-
-	if p.Get != nil {
-		count++
-	}
+// pathOperation associates a declared operation to its HTTP verb.
+type pathOperation struct {
+	verb      string
+	operation *OpenApiOperation
+}
 
-	if p.Put != nil {
-		count++
+// operations returns the declared operations of the path, in a stable order.
+func (p OpenApiPath) operations() []pathOperation {
+	var operations []pathOperation
+
+	candidates := []pathOperation{
+		{verb: http.MethodGet, operation: p.Get},
+		{verb: http.MethodPut, operation: p.Put},
+		{verb: http.MethodPost, operation: p.Post},
+		{verb: http.MethodDelete, operation: p.Delete},
+		{verb: http.MethodOptions, operation: p.Options},
+		{verb: http.MethodHead, operation: p.Head},
+		{verb: http.MethodPatch, operation: p.Patch},
+		{verb: http.MethodTrace, operation: p.Trace},
 	}
 
-	if p.Post != nil {
-		count++
+	for _, candidate := range candidates {
+		if candidate.operation != nil {
+			operations = append(operations, candidate)
+		}
 	}
+	return operations
+}
 
-	if p.Delete != nil {
-		count++
+// mergePathParameters adds the path level parameters to each operation of the path.
+// An operation parameter overrides the path parameter with the same name and location.
+func (d *OpenApiDocument) mergePathParameters() {
+	for _, path := range d.Paths {
+		if len(path.Parameters) == 0 {
+			continue
+		}
+		for _, op := range path.operations() {
+			op.operation.Parameters = mergeParameters(path.Parameters, op.operation.Parameters)
+		}
 	}
+}
 
-	if p.Options != nil {
-		count++
+// mergeParameters returns the path parameters, overridden by the operation ones, followed by
+// the other operation parameters.
+func mergeParameters(pathParameters, operationParameters []OpenApiParameter) []OpenApiParameter {
+	merged := make([]OpenApiParameter, 0, len(pathParameters)+len(operationParameters))
+	overridden := make([]bool, len(operationParameters))
+
+	for _, pathParameter := range pathParameters {
+		parameter := pathParameter
+		for index, operationParameter := range operationParameters {
+			if operationParameter.Name == pathParameter.Name && operationParameter.In == pathParameter.In {
+				parameter = operationParameter
+				overridden[index] = true
+			}
+		}
+		merged = append(merged, parameter)
 	}
 
-	if p.Head != nil {
-		count++
+	for index, operationParameter := range operationParameters {
+		if !overridden[index] {
+			merged = append(merged, operationParameter)
+		}
 	}
+	return merged
+}
 
-	if p.Patch != nil {
-		count++
+// validateParameters checks the style of every parameter of the operations.
+func (d OpenApiDocument) validateParameters() error {
+	for _, path := range sortedKeys(d.Paths) {
+		for _, op := range d.Paths[path].operations() {
+			for _, parameter := range op.operation.Parameters {
+				if err := parameter.validateStyle(); err != nil {
+					return fmt.Errorf("invalid parameter of %s %s : %w", op.verb, path, err)
+				}
+			}
+		}
 	}
+	return nil
+}
 
-	if p.Trace != nil {
-		count++
-	}
-	return count
+func (p OpenApiPath) CountOperations() int {
+	return len(p.operations())
 }
 
-func (o OpenApiPath) runTests(t *testing.T, url string) {
-	// TODO check the operations + the path
+// runPathTests runs the given operations of a path, in order.
+func runPathTests(t *testing.T, run *suiteRun, path string, servers []OpenApiServer, operations []pathOperation) {
+	// TODO check the path
 	t.Run("", func(t *testing.T) {
-
-		if o.Get != nil {
-			o.Get.runTests(t, url, http.MethodGet)
+		for _, op := range operations {
+			op.operation.runTests(t, run, path, servers, op.verb)
 		}
-
-		if o.Post != nil {
-			o.Post.runTests(t, url, http.MethodPost)
-		}
-
-		// TODO check the response schema if any
-
 	})
 }
 
 type OpenApiOperation struct {
-	Summary     string             `json:"summary" yaml:"summary"`
-	Description string             `json:"description" yaml:"description"`
-	OperationID string             `json:"operationId" yaml:"operationId"`
-	Parameters  []OpenApiParameter `json:"parameters" yaml:"parameters"`
-	Responses   OpenApiResponses   `json:"responses" yaml:"responses"`
+	Summary     string              `json:"summary" yaml:"summary"`
+	Description string              `json:"description" yaml:"description"`
+	OperationID string              `json:"operationId" yaml:"operationId"`
+	Parameters  []OpenApiParameter  `json:"parameters" yaml:"parameters"`
+	RequestBody *OpenApiRequestBody `json:"requestBody" yaml:"requestBody"`
+	// Security overrides the document security when set, an empty list removes it
+	Security  []OpenApiSecurityRequirement `json:"security" yaml:"security"`
+	Responses OpenApiResponses             `json:"responses" yaml:"responses"`
+	Servers   []OpenApiServer              `json:"servers" yaml:"servers"`
+	// AliSkip skips all the tests of the operation, with the given reason
+	AliSkip string `json:"x-ali-skip" yaml:"x-ali-skip"`
+	// AliDependsOn runs the operation after the given operationId/status tests, and skips it when one did not pass
+	AliDependsOn []string `json:"x-ali-dependsOn" yaml:"x-ali-dependsOn"`
 }
 
-func (o OpenApiOperation) runTests(t *testing.T, url, verb string) {
+func (o OpenApiOperation) runTests(t *testing.T, run *suiteRun, path string, pathServers []OpenApiServer, verb string) {
 	t.Run(o.OperationID, func(t *testing.T) {
-		ctx := operationRunContext{url: url, verb: verb, parameters: o.Parameters}
-		if o.Responses.Ok != nil {
-			t.Run("200", func(t *testing.T) {
-				o.Responses.Ok.runTest(t, ctx, http.StatusOK)
-			})
-		}
-		if o.Responses.Created != nil {
-			t.Run("201", func(t *testing.T) {
-				o.Responses.Created.runTest(t, ctx, http.StatusCreated)
-			})
+		if o.AliSkip != "" {
+			run.skipOperation(path, verb, o)
+			t.Skipf("Skipped by x-ali-skip: %s", o.AliSkip)
 		}
-		if o.Responses.BadRequest != nil {
-			t.Run("400", func(t *testing.T) {
-				o.Responses.BadRequest.runTest(t, ctx, http.StatusBadRequest)
-			})
+
+		if dependency, status, unmet := run.unmetDependency(o); unmet {
+			run.skipOperation(path, verb, o)
+			t.Skipf("Skipped, the prerequisite %s is %s", dependency, status)
 		}
-		if o.Responses.NotFound != nil {
-			t.Run("404", func(t *testing.T) {
-				o.Responses.NotFound.runTest(t, ctx, http.StatusNotFound)
-			})
+
+		url, err := run.url(path, pathServers, o.Servers)
+
+		if err != nil {
+			run.failOperation(path, verb, o)
+			t.Fatalf("Cannot resolve the server of %s : %v", o.OperationID, err)
 		}
-		if o.Responses.Expired != nil {
-			t.Run("419", func(t *testing.T) {
-				o.Responses.Expired.runTest(t, ctx, 419)
+
+		ctx := newOperationRunContext(run, path, url, verb, o)
+		for _, statusCode := range o.Responses.StatusCodes() {
+			response := o.Responses[statusCode]
+			responseCtx := ctx
+			responseCtx.coverage = run.coverageEntry(path, verb, statusCode)
+			t.Run(statusCode, func(t *testing.T) {
+				defer responseCtx.coverage.complete(t, response.HasTestData())
+				response.runTests(t, responseCtx, statusCode)
 			})
 		}
+		o.runSecurityTests(t, ctx)
 	})
 }
 
+type OpenApiRequestBody struct {
+	Description string                            `json:"description" yaml:"description"`
+	Required    bool                              `json:"required" yaml:"required"`
+	Content     map[string]OpenApiResponseContent `json:"content" yaml:"content"`
+}
+
+// MediaType chooses the content type of the request among the documented ones:
+// a json media type when there is one, the first one in alphabetical order otherwise.
+func (b OpenApiRequestBody) MediaType() (string, OpenApiResponseContent) {
+	mediaTypes := make([]string, 0, len(b.Content))
+	for mediaType := range b.Content {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+
+	if len(mediaTypes) == 0 {
+		return "application/json", OpenApiResponseContent{}
+	}
+
+	for _, mediaType := range mediaTypes {
+		if isJSONMediaType(mediaType) {
+			return mediaType, b.Content[mediaType]
+		}
+	}
+	return mediaTypes[0], b.Content[mediaTypes[0]]
+}
+
 type OpenApiParameter struct {
 	Name        string            `json:"name" yaml:"name"`
 	Description string            `json:"description" yaml:"description"`
 	In          ParameterLocation `json:"in" yaml:"in"`
 	Required    bool              `json:"required" yaml:"required"`
+	// Style is the serialization of the value, the default of the location when empty
+	Style string `json:"style" yaml:"style"`
+	// Explode sends the items of an array or object separately, true by default for the form style
+	Explode *bool `json:"explode" yaml:"explode"`
+	// AllowReserved leaves the reserved characters of a query value unescaped
+	AllowReserved bool `json:"allowReserved" yaml:"allowReserved"`
 }
 
 func (i ParameterLocation) MarshalJSON() ([]byte, error) {
@@ -166,48 +264,172 @@ func (i ParameterLocation) MarshalJSON() ([]byte, error) {
 
 func (i *ParameterLocation) UnmarshalYAML(data *yaml.Node) (err error) {
 	var inVal string
-	var result ParameterLocation
 	if err := data.Decode(&inVal); err != nil {
 		return err
 	}
+	*i, err = parseParameterLocation(inVal)
+	return err
+}
+
+func parseParameterLocation(inVal string) (ParameterLocation, error) {
 	switch strings.ToLower(inVal) {
 	case strings.ToLower(Query.String()):
-		result = Query
+		return Query, nil
 	case strings.ToLower(Header.String()):
-		result = Header
+		return Header, nil
 	case strings.ToLower(Path.String()):
-		result = Path
+		return Path, nil
 	case strings.ToLower(Cookie.String()):
-		result = Cookie
-	default:
-		// TODO test me
-		return fmt.Errorf("unknown parameter location : %s", inVal)
+		return Cookie, nil
 	}
-	*i = result
+	// TODO test me
+	return 0, fmt.Errorf("unknown parameter location : %s", inVal)
+}
+
+// DefaultResponse is the key of the response used for any status code not
+// declared by the operation.
+const DefaultResponse = "default"
+
+// OpenApiResponses maps a status code to its documented response.
+// Keys are exact status codes ("200"), ranges ("2XX") or DefaultResponse.
+type OpenApiResponses map[string]*OpenApiResponse
+
+func (r *OpenApiResponses) UnmarshalYAML(data *yaml.Node) error {
+	var raw map[string]yaml.Node
+	if err := data.Decode(&raw); err != nil {
+		return err
+	}
+
+	responses := make(OpenApiResponses, len(raw))
+	for key, node := range raw {
+		// specification extensions are not responses
+		if strings.HasPrefix(key, "x-") {
+			continue
+		}
+		statusCode := strings.ToUpper(key)
+		if key == DefaultResponse {
+			statusCode = key
+		} else if _, err := statusCodeOrder(statusCode); err != nil {
+			return err
+		}
+		if _, duplicate := responses[statusCode]; duplicate {
+			return fmt.Errorf("duplicate response status code : %s", key)
+		}
+		var response OpenApiResponse
+		if err := node.Decode(&response); err != nil {
+			return err
+		}
+		responses[statusCode] = &response
+	}
+	*r = responses
 	return nil
 }
 
-type OpenApiResponses struct {
-	Ok         *OpenApiResponse `json:"200" yaml:"200"`
-	Created    *OpenApiResponse `json:"201" yaml:"201"`
-	BadRequest *OpenApiResponse `json:"400" yaml:"400"`
-	NotFound   *OpenApiResponse `json:"404" yaml:"404"`
-	Expired    *OpenApiResponse `json:"419" yaml:"419"`
+// StatusCodes returns the documented status codes in a deterministic order:
+// numeric order, a range comes after the exact codes it contains,
+// and the default response comes last.
+func (r OpenApiResponses) StatusCodes() []string {
+	statusCodes := make([]string, 0, len(r))
+	for statusCode := range r {
+		statusCodes = append(statusCodes, statusCode)
+	}
+	sort.SliceStable(statusCodes, func(i, j int) bool {
+		left, _ := statusCodeOrder(statusCodes[i])
+		right, _ := statusCodeOrder(statusCodes[j])
+		if left != right {
+			return left < right
+		}
+		// 299 and 2XX share the same key, the exact code comes first
+		return statusCodes[i] < statusCodes[j]
+	})
+	return statusCodes
+}
+
+// Matches tells if the actual status is covered by the response documented under statusCode.
+// The default response matches any status not covered by another documented response.
+func (r OpenApiResponses) Matches(statusCode string, actual int) bool {
+	if statusCode != DefaultResponse {
+		return statusCodeMatches(statusCode, actual)
+	}
+	for documented := range r {
+		if documented != DefaultResponse && statusCodeMatches(documented, actual) {
+			return false
+		}
+	}
+	return true
+}
+
+func statusCodeMatches(statusCode string, actual int) bool {
+	if isStatusRange(statusCode) {
+		return actual/100 == int(statusCode[0]-'0')
+	}
+	return statusCode == strconv.Itoa(actual)
+}
+
+func isStatusRange(statusCode string) bool {
+	return len(statusCode) == 3 && statusCode[0] >= '1' && statusCode[0] <= '5' && statusCode[1:] == "XX"
+}
+
+// statusCodeOrder computes the sort key of a status code.
+func statusCodeOrder(statusCode string) (int, error) {
+	if statusCode == DefaultResponse {
+		return 1000, nil
+	}
+	if isStatusRange(statusCode) {
+		return int(statusCode[0]-'0')*100 + 99, nil
+	}
+	code, err := strconv.Atoi(statusCode)
+	if err != nil || code < 100 || code > 599 {
+		return 0, fmt.Errorf("unknown response status code : %s", statusCode)
+	}
+	return code, nil
 }
 
 type OpenApiResponse struct {
-	Description   string                  `json:"description" yaml:"description"`
-	Json          OpenApiResponseContent  `json:"application/json" yaml:"application/json"`
-	AliParameters map[string]AliParameter `json:"x-ali-parameters" yaml:"x-ali-parameters"`
-	AliBody       interface{}             `json:"x-ali-body" yaml:"x-ali-body"`
-	AliResponse   *AliResponse            `json:"x-ali-response" yaml:"x-ali-response"`
+	Description   string                            `json:"description" yaml:"description"`
+	Content       map[string]OpenApiResponseContent `json:"content" yaml:"content"`
+	Headers       map[string]OpenApiHeader          `json:"headers" yaml:"headers"`
+	AliParameters map[string]AliParameter           `json:"x-ali-parameters" yaml:"x-ali-parameters"`
+	AliBody       interface{}                       `json:"x-ali-body" yaml:"x-ali-body"`
+	// AliOmitBody deliberately sends no body for a required request body, for negative tests
+	AliOmitBody bool         `json:"x-ali-omit-body" yaml:"x-ali-omit-body"`
+	AliResponse *AliResponse `json:"x-ali-response" yaml:"x-ali-response"`
+	AliCases    []AliCase    `json:"x-ali-cases" yaml:"x-ali-cases"`
+	// AliCapture extracts values from the response into variables, usable as ${name} by the next tests
+	AliCapture map[string]AliCapture `json:"x-ali-capture" yaml:"x-ali-capture"`
+	// AliSkip skips the tests of the response, with the given reason
+	AliSkip string `json:"x-ali-skip" yaml:"x-ali-skip"`
+}
+
+// AliCase is one test scenario of a response. Its parameters and captures are merged over the
+// response ones, its body and response replace the response ones when set.
+type AliCase struct {
+	Name       string                  `json:"name" yaml:"name"`
+	Parameters map[string]AliParameter `json:"parameters" yaml:"parameters"`
+	Body       interface{}             `json:"body" yaml:"body"`
+	OmitBody   bool                    `json:"omitBody" yaml:"omitBody"`
+	Response   *AliResponse            `json:"response" yaml:"response"`
+	Capture    map[string]AliCapture   `json:"capture" yaml:"capture"`
 }
 
 type AliResponse struct {
-	// Ignore id array of json pointer string to exclude from check
-	Ignore                []string    `json:"ignore" yaml:"ignore"`
-	AcceptAdditionalProps bool        `json:"acceptAdditionalProps" yaml:"acceptAdditionalProps"`
-	Expected              interface{} `json:"expected" yaml:"expected"`
+	// Ignore is an array of json pointer strings to exclude from check.
+	// A "*" token matches every item of an array, e.g. /items/*/createdAt
+	Ignore                []string `json:"ignore" yaml:"ignore"`
+	AcceptAdditionalProps bool     `json:"acceptAdditionalProps" yaml:"acceptAdditionalProps"`
+	// Expected is the expected response payload, not compared when missing
+	Expected interface{} `json:"expected" yaml:"expected"`
+	// Headers are the expected response headers, by name
+	Headers map[string]AliHeader `json:"headers" yaml:"headers"`
+	// Arrays sets how all the arrays are compared, ArraysAt overrides it for the arrays at
+	// the given json pointers. A "*" token matches any item or property.
+	Arrays   AliArrays            `json:"arrays" yaml:"arrays"`
+	ArraysAt map[string]AliArrays `json:"arraysAt" yaml:"arraysAt"`
+}
+
+// checksPayload tells if the returned payload is checked, against an expected payload or array options.
+func (r AliResponse) checksPayload() bool {
+	return r.Expected != nil || r.hasArrayOptions()
 }
 
 func (r AliResponse) Compare(actualPayload []byte) (bool, string) {
@@ -217,29 +439,101 @@ func (r AliResponse) Compare(actualPayload []byte) (bool, string) {
 		return false, fmt.Sprintf("Got unexpected marshalling error (%v) when reading expected response from spec", err)
 	}
 
+	if len(r.Ignore) > 0 {
+		actualPayload, expectedPayload, err = r.removeIgnored(actualPayload, expectedPayload)
+
+		if err != nil {
+			return false, fmt.Sprintf("Cannot apply the ignore list (%v)", err)
+		}
+	}
+
+	var mismatches []string
+	if r.hasArrayOptions() {
+		actualPayload, mismatches, err = r.alignPayloads(actualPayload, expectedPayload)
+
+		if err != nil {
+			return false, fmt.Sprintf("Cannot apply the array options (%v)", err)
+		}
+	}
+
+	// only the array lengths are checked without an expected payload
+	if r.Expected == nil {
+		return len(mismatches) == 0, strings.Join(mismatches, "\n")
+	}
+
+	if containsMatcher(normalizeJSON(r.Expected)) {
+		var matcherMismatches []string
+		expectedPayload, matcherMismatches, err = matchPayloads(actualPayload, expectedPayload)
+
+		if err != nil {
+			return false, fmt.Sprintf("Cannot apply the matchers (%v)", err)
+		}
+		mismatches = append(mismatches, matcherMismatches...)
+	}
+
 	opt := diff.DefaultJSONOptions()
 
 	res, details := diff.Compare(actualPayload, expectedPayload, &opt)
 
+	if len(mismatches) > 0 {
+		return false, fmt.Sprintf("%s\n%s", strings.Join(mismatches, "\n"), details)
+	}
+
 	return res == diff.FullMatch || (res == diff.SupersetMatch && r.AcceptAdditionalProps), details
 }
 
+// removeIgnored removes the values targeted by the Ignore pointers from both payloads.
+func (r AliResponse) removeIgnored(actualPayload, expectedPayload []byte) ([]byte, []byte, error) {
+	var pointers [][]string
+	for _, pointer := range r.Ignore {
+		tokens, err := parseJSONPointer(pointer)
+		if err != nil {
+			return nil, nil, err
+		}
+		pointers = append(pointers, tokens)
+	}
+
+	var actual, expected interface{}
+	if err := json.Unmarshal(actualPayload, &actual); err != nil {
+		return nil, nil, fmt.Errorf("cannot decode the returned payload : %w", err)
+	}
+	if err := json.Unmarshal(expectedPayload, &expected); err != nil {
+		return nil, nil, fmt.Errorf("cannot decode the expected payload : %w", err)
+	}
+
+	actual = removeJSONPointers(actual, pointers)
+	expected = removeJSONPointers(expected, pointers)
+
+	actualPayload, err := json.Marshal(actual)
+	if err != nil {
+		return nil, nil, err
+	}
+	expectedPayload, err = json.Marshal(expected)
+	return actualPayload, expectedPayload, err
+}
+
 func (o OpenApiResponse) ResolveURL(rawUrl string, params []OpenApiParameter) string {
-	resolvedURL := strings.TrimSuffix(rawUrl, "/")
+	resolvedURL := rawUrl
 	queryParams := ""
 	queryPrefix := "?"
 
 	for _, param := range params {
 		switch param.In {
 		case Path:
-			// TODO handle not provided parameter => failed
-			paramValue, _ := o.AliParameters[param.Name]
-			resolvedURL = strings.ReplaceAll(resolvedURL, fmt.Sprintf("{%s}", param.Name), fmt.Sprintf("%s", paramValue.Value))
+			// a missing value is reported by MissingParameters, an omitted one leaves an empty segment
+			paramValue, present := o.AliParameters[param.Name]
+			if present && paramValue.Omit {
+				resolvedURL = strings.ReplaceAll(resolvedURL, fmt.Sprintf("{%s}", param.Name), "")
+			} else if present {
+				resolvedURL = strings.ReplaceAll(resolvedURL, fmt.Sprintf("{%s}", param.Name), param.pathValue(paramValue.Value))
+			}
 		case Query:
 			paramValue, present := o.AliParameters[param.Name]
-			if present {
-				queryParams = fmt.Sprintf("%s%s%s=%s", queryParams, queryPrefix, param.Name, url.QueryEscape(fmt.Sprintf("%s", paramValue.Value)))
-				queryPrefix = "&"
+			if present && !paramValue.Omit {
+				for _, pair := range param.queryPairs(paramValue.Value) {
+					queryParams = queryParams + queryPrefix + pair
+					queryPrefix = "&"
+				}
 			}
 		}
 	}
@@ -248,45 +542,177 @@ func (o OpenApiResponse) ResolveURL(rawUrl string, params []OpenApiParameter) st
 	return resolvedURL
 }
 
-func (o OpenApiResponse) runTest(t *testing.T, ctx operationRunContext, status int) {
+// MissingParameters returns the required parameters without any value.
+// A path parameter is always required, as its placeholder cannot be left in the url.
+// A parameter explicitly omitted is not reported.
+func (o OpenApiResponse) MissingParameters(params []OpenApiParameter) []OpenApiParameter {
+	var missing []OpenApiParameter
+	for _, param := range params {
+		if _, present := o.AliParameters[param.Name]; (param.Required || param.In == Path) && !present {
+			missing = append(missing, param)
+		}
+	}
+	return missing
+}
+
+// ApplyParameters sets the header and cookie parameters on the request.
+func (o OpenApiResponse) ApplyParameters(request *http.Request, params []OpenApiParameter) {
+	for _, param := range params {
+		paramValue, present := o.AliParameters[param.Name]
+		if !present || paramValue.Omit {
+			continue
+		}
+
+		switch param.In {
+		case Header:
+			if isReservedHeader(param.Name) {
+				continue
+			}
+			request.Header.Set(param.Name, param.headerValue(paramValue.Value))
+		case Cookie:
+			cookie := strings.Join(param.cookiePairs(paramValue.Value), "; ")
+			if existing := request.Header.Get("Cookie"); existing != "" {
+				cookie = existing + "; " + cookie
+			}
+			request.Header.Set("Cookie", cookie)
+		}
+	}
+}
+
+// isReservedHeader tells if a header parameter must be ignored:
+// Accept, Content-Type and Authorization are described by other open api fields.
+func isReservedHeader(name string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case "Accept", "Content-Type", "Authorization":
+		return true
+	}
+	return false
+}
+
+// HasTestData tells if the response declares any x-ali test data.
+func (o OpenApiResponse) HasTestData() bool {
+	return len(o.AliParameters) > 0 || o.AliBody != nil || o.AliOmitBody || o.AliResponse != nil || len(o.AliCases) > 0 || len(o.AliCapture) > 0
+}
+
+// WithCase returns the response test data overridden by the given case.
+func (o OpenApiResponse) WithCase(aliCase AliCase) OpenApiResponse {
+	parameters := make(map[string]AliParameter, len(o.AliParameters)+len(aliCase.Parameters))
+	for name, param := range o.AliParameters {
+		parameters[name] = param
+	}
+	for name, param := range aliCase.Parameters {
+		parameters[name] = param
+	}
+	o.AliParameters = parameters
+
+	if aliCase.Body != nil {
+		o.AliBody = aliCase.Body
+	}
+
+	if aliCase.OmitBody {
+		o.AliBody = nil
+		o.AliOmitBody = true
+	}
+
+	if aliCase.Response != nil {
+		o.AliResponse = aliCase.Response
+	}
+
+	if len(aliCase.Capture) > 0 {
+		captures := make(map[string]AliCapture, len(o.AliCapture)+len(aliCase.Capture))
+		for name, capture := range o.AliCapture {
+			captures[name] = capture
+		}
+		for name, capture := range aliCase.Capture {
+			captures[name] = capture
+		}
+		o.AliCapture = captures
+	}
+
+	o.AliCases = nil
+	return o
+}
+
+// runOperationHooks runs the operation hooks around the request described by the response test data.
+func (o OpenApiResponse) runOperationHooks(t *testing.T, ctx operationRunContext, statusCode string) {
+	ctx.run.runOperationHooks(t, OperationHookParameters{
+		OperationID: ctx.operationID,
+		StatusCode:  statusCode,
+		Parameters:  o.AliParameters,
+		Body:        o.AliBody,
+	})
+}
+
+// newRequest builds the request described by the response test data, without any credential.
+func (o OpenApiResponse) newRequest(ctx operationRunContext) (*http.Request, error) {
 	var reader io.Reader
 	var err error
-	resolvedURL := o.ResolveURL(ctx.url, ctx.parameters)
+	contentType := ""
+
 	if o.AliBody != nil {
-		reader, err = ioReader(o.AliBody)
+		contentType, _ = ctx.requestContentType()
+		reader, err = encodeBody(o.AliBody, contentType)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode x-ali-body : %w", err)
+		}
 	}
 
+	request, err := http.NewRequest(ctx.verb, o.ResolveURL(ctx.url, ctx.parameters), reader)
+
 	if err != nil {
-		t.Fatalf("Got unexpected marshalling error (%v) when performing a %s on %s", err, ctx.verb, resolvedURL)
+		return nil, err
 	}
 
-	// TODO handle the error
-	request, _ := http.NewRequest(ctx.verb, resolvedURL, reader)
 	request.Header.Add("Accept", "application/json")
-
-	netClient := &http.Client{
-		Timeout: time.Second * 10,
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
 	}
+	o.ApplyParameters(request, ctx.parameters)
 
-	response, err := netClient.Do(request)
+	return request, nil
+}
 
-	if err != nil {
-		t.Fatalf("Got unexpected error (%v) when performing a %s on %s", err, ctx.verb, resolvedURL)
+// runTests runs each x-ali-cases as a named subtest, or the response test data when there is no case.
+func (o OpenApiResponse) runTests(t *testing.T, ctx operationRunContext, statusCode string) {
+	if o.AliSkip != "" {
+		t.Skipf("Skipped by x-ali-skip: %s", o.AliSkip)
 	}
 
-	if response.StatusCode != status {
-		t.Fatalf("Expect status %d but got status %d", status, response.StatusCode)
+	if ctx.run.parameters.Strict && !o.HasTestData() {
+		t.Fatalf("Strict mode: no test data for %s (%s). Add x-ali-* test data, or skip it with x-ali-skip: \"reason\"", ctx.operationID, statusCode)
 	}
 
-	// Stop the process now, no returned data to verify
-	if o.AliResponse == nil {
+	if len(o.AliCases) == 0 {
+		o.runTest(t, ctx, statusCode)
 		return
 	}
 
-	actualPayload, err := io.ReadAll(response.Body)
+	for index, aliCase := range o.AliCases {
+		name := aliCase.Name
+		if name == "" {
+			name = fmt.Sprintf("case %d", index)
+		}
+		response := o.WithCase(aliCase)
+		t.Run(name, func(t *testing.T) {
+			response.runTest(t, ctx, statusCode)
+		})
+	}
+}
+
+func (o OpenApiResponse) runTest(t *testing.T, ctx operationRunContext, statusCode string) {
+	o, err := o.Interpolate(ctx.run.variables)
 
 	if err != nil {
-		t.Fatalf("Got unexpected error (%v) when reading response from %s on %s", err, ctx.verb, resolvedURL)
+		t.Fatalf("Cannot use the test data of %s (%s) : %v", ctx.operationID, statusCode, err)
+	}
+
+	_, response, actualPayload := o.exchange(t, ctx, statusCode)
+
+	o.captureVariables(t, ctx, statusCode, response.Header, actualPayload)
+
+	// Stop the process now, no returned data to verify
+	if o.AliResponse == nil || !o.AliResponse.checksPayload() {
+		return
 	}
 
 	diffPass, diffDetails := o.AliResponse.Compare(actualPayload)
@@ -299,7 +725,172 @@ func (o OpenApiResponse) runTest(t *testing.T, ctx operationRunContext, status i
 
 }
 
-func ioReader(data interface{}) (io.Reader, error) {
+// exchange sends the request described by the test data, and checks the response against
+// the documented status code, headers and schema. It returns the sent request, the response
+// and its payload, nil when the verb returns no body.
+func (o OpenApiResponse) exchange(t *testing.T, ctx operationRunContext, statusCode string) (*http.Request, *http.Response, []byte) {
+	if missing := o.MissingParameters(ctx.parameters); len(missing) > 0 {
+		var names []string
+		for _, param := range missing {
+			names = append(names, fmt.Sprintf("%s parameter %s", strings.ToLower(param.In.String()), param.Name))
+		}
+		t.Fatalf("Missing value for the required %s of %s (%s). Set it in x-ali-parameters, or mark it with omit: true", strings.Join(names, ", "), ctx.operationID, statusCode)
+	}
+
+	if o.AliBody == nil && ctx.requestBody != nil && ctx.requestBody.Required && !o.AliOmitBody {
+		t.Fatalf("Missing x-ali-body for the required request body of %s (%s). Set it, or mark it with x-ali-omit-body: true", ctx.operationID, statusCode)
+	}
+
+	if o.AliBody != nil {
+		if !verbAcceptsRequestBody(ctx.verb) {
+			t.Fatalf("A %s request cannot carry a body, remove x-ali-body for %s", ctx.verb, ctx.url)
+		}
+
+		// a fixture for a failure response may deliberately break the schema
+		contentType, content := ctx.requestContentType()
+		if content.Schema != nil && isJSONMediaType(contentType) && !isErrorStatus(statusCode) {
+			if violations := content.Schema.ValidateRequest(normalizeJSON(o.AliBody)); len(violations) > 0 {
+				t.Fatalf("Invalid x-ali-body fixture for %s (%s), it does not respect the request body schema :\n%s", ctx.operationID, statusCode, joinViolations(violations))
+			}
+		}
+	}
+
+	o.runOperationHooks(t, ctx, statusCode)
+
+	request, err := o.newRequest(ctx)
+
+	if err != nil {
+		t.Fatalf("Got unexpected error (%v) when building a %s on %s", err, ctx.verb, ctx.url)
+	}
+
+	resolvedURL := request.URL.String()
+
+	if err := ctx.run.authenticate(request, ctx.security); errors.Is(err, errNoCredentials) && !ctx.run.parameters.Strict {
+		t.Skipf("Skipped, %v", err)
+	} else if err != nil {
+		t.Fatalf("Cannot authenticate the %s on %s : %v", ctx.verb, resolvedURL, err)
+	}
+
+	response, err := ctx.run.do(request)
+
+	if err != nil {
+		t.Fatalf("Got unexpected error (%v) when performing a %s on %s", err, ctx.verb, resolvedURL)
+	}
+
+	defer closeBody(response)
+
+	if ctx.coverage != nil {
+		ctx.coverage.Exercised = true
+	}
+
+	if !ctx.responses.Matches(statusCode, response.StatusCode) {
+		t.Fatalf("Expect status %s but got status %d", statusCode, response.StatusCode)
+	}
+
+	headerMessages := o.ValidateHeaders(response.Header)
+	if o.AliResponse != nil {
+		headerMessages = append(headerMessages, o.AliResponse.CompareHeaders(response.Header)...)
+	}
+
+	if len(headerMessages) > 0 {
+		t.Errorf("Got differences on response headers :\n%s", strings.Join(headerMessages, "\n"))
+	}
+
+	if !verbReturnsResponseBody(ctx.verb) {
+		if o.AliResponse != nil && o.AliResponse.checksPayload() {
+			t.Fatalf("A %s response has no body, x-ali-response cannot be checked on %s", ctx.verb, resolvedURL)
+		}
+		return request, response, nil
+	}
+
+	actualPayload, err := io.ReadAll(response.Body)
+
+	if err != nil {
+		t.Fatalf("Got unexpected error (%v) when reading response from %s on %s", err, ctx.verb, resolvedURL)
+	}
+
+	if violations := o.ValidateContent(response.Header.Get("Content-Type"), actualPayload); len(violations) > 0 {
+		t.Errorf("Got schema violations on response payload %s :\n%s", string(actualPayload), strings.Join(violations, "\n"))
+	}
+
+	return request, response, actualPayload
+}
+
+// ValidateContent checks a response payload against the schema documented for its content type.
+// Only json media types are validated, an empty payload is never checked.
+func (o OpenApiResponse) ValidateContent(contentType string, payload []byte) []string {
+	if len(o.Content) == 0 || len(bytes.TrimSpace(payload)) == 0 {
+		return nil
+	}
+
+	mediaType, content, found := o.contentFor(contentType)
+	if !found {
+		return []string{fmt.Sprintf("content type %q is not documented", contentType)}
+	}
+
+	if content.Schema == nil || !isJSONMediaType(mediaType) {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(payload, &value); err != nil {
+		return []string{fmt.Sprintf("cannot decode %s payload : %v", mediaType, err)}
+	}
+
+	var messages []string
+	for _, violation := range content.Schema.Validate(value) {
+		messages = append(messages, violation.String())
+	}
+	return messages
+}
+
+func joinViolations(violations []SchemaViolation) string {
+	messages := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		messages = append(messages, violation.String())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// contentFor finds the documented content matching a content type, wildcards included.
+func (o OpenApiResponse) contentFor(contentType string) (string, OpenApiResponseContent, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", OpenApiResponseContent{}, false
+	}
+
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	documentedTypes := map[string]string{}
+	for documented := range o.Content {
+		documentedType, _, err := mime.ParseMediaType(documented)
+		if err == nil {
+			documentedTypes[documentedType] = documented
+		}
+	}
+
+	for _, candidate := range []string{mediaType, mainType + "/*", "*/*"} {
+		if documented, found := documentedTypes[candidate]; found {
+			return mediaType, o.Content[documented], true
+		}
+	}
+	return "", OpenApiResponseContent{}, false
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// encodeBody encodes the request body for the given media type.
+// Non json media types are only supported with a raw string body.
+func encodeBody(data interface{}, mediaType string) (io.Reader, error) {
+	if !isJSONMediaType(mediaType) {
+		raw, ok := data.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot encode a %T body as %s, use a string", data, mediaType)
+		}
+		return strings.NewReader(raw), nil
+	}
+
 	jsonEncoded, err := json.Marshal(data)
 	if err != nil {
 		return nil, err
@@ -307,10 +898,18 @@ func ioReader(data interface{}) (io.Reader, error) {
 	return bytes.NewBuffer(jsonEncoded), nil
 }
 
+// isErrorStatus tells if a documented status code describes a client or server error.
+func isErrorStatus(statusCode string) bool {
+	return statusCode == DefaultResponse || statusCode[0] == '4' || statusCode[0] == '5'
+}
+
+// OpenApiResponseContent is the media type object of a response or a request body.
 type OpenApiResponseContent struct {
-	Schema interface{} `json:"schema" yaml:"schema"`
+	Schema *Schema `json:"schema" yaml:"schema"`
 }
 
 type AliParameter struct {
 	Value any `json:"value" yaml:"value"`
+	// Omit deliberately leaves the parameter out of the request, for negative tests
+	Omit bool `json:"omit" yaml:"omit"`
 }
@@ -1,9 +1,13 @@
 package alitest_test
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/toolzup/alitest"
+	"gopkg.in/yaml.v3"
 )
 
 func TestMatchBodyResponse(t *testing.T) {
@@ -106,6 +110,83 @@ func TestMatchBodyResponse(t *testing.T) {
 			bodyResponse: []byte(`[{"name": "Rex"}]`),
 			identical:    false,
 		},
+		{
+			description: "ignored generated attributes",
+			response: alitest.AliResponse{
+				Expected: map[string]interface{}{"id": "some-id", "name": "Medor"},
+				Ignore:   []string{"/id", "/createdAt"},
+			},
+			bodyResponse: []byte(`{"id": "0a62b985", "name": "Medor", "createdAt": "2024-02-23T10:00:00Z"}`),
+			identical:    true,
+		},
+		{
+			description: "ignored attributes do not hide other differences",
+			response: alitest.AliResponse{
+				Expected: map[string]interface{}{"name": "Medor"},
+				Ignore:   []string{"/id"},
+			},
+			bodyResponse: []byte(`{"id": "0a62b985", "name": "Rex"}`),
+			identical:    false,
+		},
+		{
+			description: "ignored attributes in every array item",
+			response: alitest.AliResponse{
+				Expected: map[string]interface{}{
+					"items": []interface{}{
+						map[string]interface{}{"name": "Medor"},
+						map[string]interface{}{"name": "Rex"},
+					},
+				},
+				Ignore: []string{"/items/*/createdAt"},
+			},
+			bodyResponse: []byte(`{"items": [{"name": "Medor", "createdAt": "2024-02-23"}, {"name": "Rex", "createdAt": "2024-02-24"}]}`),
+			identical:    true,
+		},
+		{
+			description: "ignored escaped attribute",
+			response: alitest.AliResponse{
+				Expected: map[string]interface{}{"name": "Medor", "links": map[string]interface{}{}},
+				Ignore:   []string{"/links/self~1href"},
+			},
+			bodyResponse: []byte(`{"name": "Medor", "links": {"self/href": "/pet/1"}}`),
+			identical:    true,
+		},
+		{
+			description: "ignored array items are indexed in the returned array",
+			response: alitest.AliResponse{
+				Expected: map[string]interface{}{"items": []interface{}{"some-pet", "other-pet", "Felix"}},
+				Ignore:   []string{"/items/0", "/items/1"},
+			},
+			bodyResponse: []byte(`{"items": ["Medor", "Rex", "Felix"]}`),
+			identical:    true,
+		},
+		{
+			description: "ignored whole document",
+			response: alitest.AliResponse{
+				Expected: map[string]interface{}{"name": "Medor"},
+				Ignore:   []string{""},
+			},
+			bodyResponse: []byte(`{"name": "Rex"}`),
+			identical:    true,
+		},
+		{
+			description: "malformed ignored pointer",
+			response: alitest.AliResponse{
+				Expected: map[string]interface{}{"name": "Medor"},
+				Ignore:   []string{"id"},
+			},
+			bodyResponse: []byte(`{"name": "Medor"}`),
+			identical:    false,
+		},
+		{
+			description: "malformed escape in ignored pointer",
+			response: alitest.AliResponse{
+				Expected: map[string]interface{}{"name": "Medor"},
+				Ignore:   []string{"/na~2me"},
+			},
+			bodyResponse: []byte(`{"name": "Medor"}`),
+			identical:    false,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -188,6 +269,31 @@ func TestResolveURL(t *testing.T) {
 			url:         "some/path/some-event/races/some-race",
 			expectedURL: "some/path/some-event/races/some-race?search=ert%2Btiti&from=f%3Bgihzdfgkjhfdgj",
 		},
+		{
+			name: "omitted parameters",
+			response: alitest.OpenApiResponse{
+				AliParameters: map[string]alitest.AliParameter{
+					"petId": {
+						Omit: true,
+					},
+					"search": {
+						Omit: true,
+					},
+				},
+			},
+			params: []alitest.OpenApiParameter{
+				{
+					Name: "petId",
+					In:   alitest.Path,
+				},
+				{
+					Name: "search",
+					In:   alitest.Query,
+				},
+			},
+			url:         "some/path/{petId}/races",
+			expectedURL: "some/path//races",
+		},
 	}
 
 	for _, test := range tests {
@@ -201,3 +307,321 @@ func TestResolveURL(t *testing.T) {
 		})
 	}
 }
+
+func TestResponsesStatusCodes(t *testing.T) {
+	tests := []struct {
+		name        string
+		responses   string
+		statusCodes []string
+		err         string
+	}{
+		{
+			name:        "exact codes",
+			responses:   "404: {}\n200: {}\n201: {}",
+			statusCodes: []string{"200", "201", "404"},
+		},
+		{
+			name:        "ranges and default",
+			responses:   "default: {}\n5XX: {}\n4xx: {}\n409: {}\n204: {}\n503: {}",
+			statusCodes: []string{"204", "409", "4XX", "503", "5XX", "default"},
+		},
+		{
+			name:        "extensions are ignored",
+			responses:   "200: {}\nx-ali-something: true",
+			statusCodes: []string{"200"},
+		},
+		{
+			name:        "exact code sharing the order of a range",
+			responses:   "2XX: {}\n299: {}\n200: {}",
+			statusCodes: []string{"200", "299", "2XX"},
+		},
+		{
+			name:      "unknown status code",
+			responses: "200: {}\n6XX: {}",
+			err:       "unknown response status code : 6XX",
+		},
+		{
+			name:      "duplicate range",
+			responses: "4xx: {}\n4XX: {}",
+			err:       "duplicate response status code",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var responses alitest.OpenApiResponses
+
+			err := yaml.Unmarshal([]byte(test.responses), &responses)
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expect error %s, got %v", test.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expect nil error, got %v", err)
+			}
+
+			if strings.Join(responses.StatusCodes(), ",") != strings.Join(test.statusCodes, ",") {
+				t.Fatalf("expect %v, got %v", test.statusCodes, responses.StatusCodes())
+			}
+		})
+	}
+}
+
+func TestParseInvalidStatusCode(t *testing.T) {
+	spec := `
+info:
+  title: invalid status code
+paths:
+  /pet:
+    get:
+      responses:
+        20X:
+          description: not a range
+`
+
+	_, err := alitest.ParseString(spec)
+
+	if err == nil || err.Error() != "invalid open api document : unknown response status code : 20X" {
+		t.Fatalf("expect unknown status code error, got %v", err)
+	}
+}
+
+func TestResponsesMatches(t *testing.T) {
+	responses := alitest.OpenApiResponses{
+		"200":                   {},
+		"4XX":                   {},
+		"404":                   {},
+		alitest.DefaultResponse: {},
+	}
+
+	tests := []struct {
+		statusCode string
+		actual     int
+		matches    bool
+	}{
+		{statusCode: "200", actual: 200, matches: true},
+		{statusCode: "200", actual: 201, matches: false},
+		{statusCode: "4XX", actual: 409, matches: true},
+		{statusCode: "4XX", actual: 500, matches: false},
+		{statusCode: "404", actual: 404, matches: true},
+		{statusCode: alitest.DefaultResponse, actual: 500, matches: true},
+		{statusCode: alitest.DefaultResponse, actual: 418, matches: false},
+		{statusCode: alitest.DefaultResponse, actual: 200, matches: false},
+	}
+
+	for _, test := range tests {
+		if responses.Matches(test.statusCode, test.actual) != test.matches {
+			t.Errorf("expect %s matching %d to be %t", test.statusCode, test.actual, test.matches)
+		}
+	}
+}
+
+func TestRequestBodyMediaType(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      alitest.OpenApiRequestBody
+		mediaType string
+	}{
+		{
+			name:      "no documented content",
+			body:      alitest.OpenApiRequestBody{},
+			mediaType: "application/json",
+		},
+		{
+			name: "json is preferred",
+			body: alitest.OpenApiRequestBody{Content: map[string]alitest.OpenApiResponseContent{
+				"application/xml":              {},
+				"application/merge-patch+json": {},
+			}},
+			mediaType: "application/merge-patch+json",
+		},
+		{
+			name: "first documented media type",
+			body: alitest.OpenApiRequestBody{Content: map[string]alitest.OpenApiResponseContent{
+				"text/plain":      {},
+				"application/xml": {},
+			}},
+			mediaType: "application/xml",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mediaType, _ := test.body.MediaType()
+
+			if mediaType != test.mediaType {
+				t.Fatalf("expect %s, got %s", test.mediaType, mediaType)
+			}
+		})
+	}
+}
+
+func TestValidateContent(t *testing.T) {
+	var response alitest.OpenApiResponse
+
+	err := yaml.Unmarshal([]byte(`
+content:
+  application/json:
+    schema:
+      type: object
+      required: [name]
+  text/*:
+    schema:
+      type: string
+`), &response)
+
+	if err != nil {
+		t.Fatalf("expect nil error, got %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		payload     string
+		violations  []string
+	}{
+		{
+			name:        "valid json payload",
+			contentType: "application/json; charset=utf-8",
+			payload:     `{"name": "Medor"}`,
+		},
+		{
+			name:        "invalid json payload",
+			contentType: "application/json",
+			payload:     `{"id": 5}`,
+			violations:  []string{"# (required): missing property name"},
+		},
+		{
+			name:        "empty payload",
+			contentType: "application/json",
+		},
+		{
+			name:        "wildcard media type is not validated",
+			contentType: "text/plain",
+			payload:     `Medor`,
+		},
+		{
+			name:        "undocumented media type",
+			contentType: "application/xml",
+			payload:     `<pet/>`,
+			violations:  []string{`content type "application/xml" is not documented`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			violations := response.ValidateContent(test.contentType, []byte(test.payload))
+
+			if strings.Join(violations, "\n") != strings.Join(test.violations, "\n") {
+				t.Fatalf("expect violations %v, got %v", test.violations, violations)
+			}
+		})
+	}
+}
+
+func TestHasTestData(t *testing.T) {
+	tests := []struct {
+		name     string
+		response alitest.OpenApiResponse
+		hasData  bool
+	}{
+		{name: "no test data", response: alitest.OpenApiResponse{Description: "some response"}},
+		{name: "parameters", response: alitest.OpenApiResponse{AliParameters: map[string]alitest.AliParameter{"petId": {Value: 5}}}, hasData: true},
+		{name: "body", response: alitest.OpenApiResponse{AliBody: "Medor"}, hasData: true},
+		{name: "omitted body", response: alitest.OpenApiResponse{AliOmitBody: true}, hasData: true},
+		{name: "expected response", response: alitest.OpenApiResponse{AliResponse: &alitest.AliResponse{}}, hasData: true},
+		{name: "cases", response: alitest.OpenApiResponse{AliCases: []alitest.AliCase{{Name: "some case"}}}, hasData: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.response.HasTestData() != test.hasData {
+				t.Fatalf("expect HasTestData to be %t", test.hasData)
+			}
+		})
+	}
+}
+
+func TestApplyParameters(t *testing.T) {
+	response := alitest.OpenApiResponse{
+		AliParameters: map[string]alitest.AliParameter{
+			"X-Tenant-Id":   {Value: "some-tenant"},
+			"X-Page-Size":   {Value: 20},
+			"session":       {Value: "some-session"},
+			"Authorization": {Value: "Bearer ignored"},
+			"petId":         {Value: "medor"},
+		},
+	}
+
+	params := []alitest.OpenApiParameter{
+		{Name: "X-Tenant-Id", In: alitest.Header},
+		{Name: "X-Page-Size", In: alitest.Header},
+		{Name: "X-Undocumented", In: alitest.Header},
+		{Name: "session", In: alitest.Cookie},
+		{Name: "Authorization", In: alitest.Header},
+		{Name: "petId", In: alitest.Path},
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/pet/medor", nil)
+
+	response.ApplyParameters(request, params)
+
+	headers := map[string]string{
+		"X-Tenant-Id":    "some-tenant",
+		"X-Page-Size":    "20",
+		"X-Undocumented": "",
+		"Authorization":  "",
+		"petId":          "",
+	}
+
+	for name, value := range headers {
+		if request.Header.Get(name) != value {
+			t.Errorf("expect header %s to be %q, got %q", name, value, request.Header.Get(name))
+		}
+	}
+
+	cookie, err := request.Cookie("session")
+
+	if err != nil {
+		t.Fatalf("expect nil error, got %v", err)
+	}
+
+	if cookie.Value != "some-session" {
+		t.Fatalf("expect cookie session to be some-session, got %s", cookie.Value)
+	}
+
+	if len(request.Cookies()) != 1 {
+		t.Fatalf("expect 1 cookie, got %v", request.Cookies())
+	}
+}
+
+func TestMissingParameters(t *testing.T) {
+	response := alitest.OpenApiResponse{
+		AliParameters: map[string]alitest.AliParameter{
+			"petId":       {Value: "medor"},
+			"X-Tenant-Id": {Omit: true},
+		},
+	}
+
+	params := []alitest.OpenApiParameter{
+		{Name: "petId", In: alitest.Path, Required: true},
+		{Name: "X-Tenant-Id", In: alitest.Header, Required: true},
+		{Name: "session", In: alitest.Cookie, Required: true},
+		{Name: "search", In: alitest.Query, Required: true},
+		{Name: "from", In: alitest.Query},
+		{Name: "raceId", In: alitest.Path},
+	}
+
+	var missing []string
+	for _, param := range response.MissingParameters(params) {
+		missing = append(missing, param.Name)
+	}
+
+	if strings.Join(missing, ",") != "session,search,raceId" {
+		t.Fatalf("expect session, search and raceId to be missing, got %v", missing)
+	}
+}
@@ -3,6 +3,7 @@ package alitest
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"testing"
 
@@ -12,29 +13,59 @@ import (
 type (
 	// IntegrationTestSuite .... TODO, complete me
 	IntegrationTestSuite struct {
-		doc OpenApiDocument
+		doc       OpenApiDocument
+		workflows []ArazzoWorkflow
 	}
 
-	// TODO: doc me
+	// RunParameters configures a run of the integration test suite.
 	RunParameters struct {
+		// URL is the base url of the tested server. It replaces the absolute server urls
+		// of the specification, and is the base of the relative ones.
 		URL string
+		// ServerDescription picks the document server with this description, ServerIndex picks it by position otherwise.
+		// The servers of a path or an operation are picked the same way, their first one when none matches
+		ServerDescription string
+		ServerIndex       int
+		// ServerVariables override the default values of the server variables
+		ServerVariables map[string]string
+		// Strict fails the tests of any response without x-ali test data, unless skipped,
+		// and of any secured operation without credentials, skipped otherwise
+		Strict bool
+		// Credentials are the secrets of the security schemes, by scheme name
+		Credentials map[string]Credential
+		// BeforeSuite and AfterSuite seed and clean the tested environment, once per run
+		BeforeSuite SuiteHook
+		AfterSuite  SuiteHook
+		// BeforeOperation and AfterOperation are called around each request, by operationId
+		BeforeOperation map[string]OperationHook
+		AfterOperation  map[string]OperationHook
+		// Client sends all the requests of the run, a client with a 10s timeout is used when nil
+		Client *http.Client
+		// Transport is the round tripper of the default client, it is ignored when Client is set
+		Transport http.RoundTripper
+		// Handler serves the requests in process, instead of a server at URL.
+		// It replaces the transport of the client, and a relative server is on http://localhost.
+		Handler http.Handler
+		// Middlewares wrap the transport of the client, the first one sees the requests first
+		Middlewares []Middleware
+		// WorkflowInputs are the inputs of the Arazzo workflows, by workflowId
+		WorkflowInputs map[string]map[string]interface{}
 	}
 )
 
 func ParseFile(fileName string) (IntegrationTestSuite, error) {
 	var testSuite IntegrationTestSuite
-	var doc OpenApiDocument
-	f, err := os.Open(fileName)
+
+	content, err := os.ReadFile(fileName)
 
 	if err != nil {
 		return testSuite, err
 	}
 
-	yamlDecoder := yaml.NewDecoder(f)
-	err = yamlDecoder.Decode(&doc)
+	doc, err := parseDocument(content, fileName)
 
 	if err != nil {
-		return testSuite, errors.New("cannot unmarshal into an open api document. Please check the input.")
+		return testSuite, err
 	}
 
 	testSuite.doc = doc
@@ -44,11 +75,11 @@ func ParseFile(fileName string) (IntegrationTestSuite, error) {
 
 func ParseString(specContent string) (IntegrationTestSuite, error) {
 	var testSuite IntegrationTestSuite
-	var doc OpenApiDocument
 
-	err := yaml.Unmarshal([]byte(specContent), &doc)
+	doc, err := parseDocument([]byte(specContent), "")
+
 	if err != nil {
-		return testSuite, errors.New("cannot unmarshal into an open api document. Please check the input.")
+		return testSuite, err
 	}
 
 	testSuite.doc = doc
@@ -56,6 +87,60 @@ func ParseString(specContent string) (IntegrationTestSuite, error) {
 	return testSuite, nil
 }
 
+// parseDocument decodes an open api document, resolving its references.
+// Relative references are read from the directory of fileName.
+func parseDocument(content []byte, fileName string) (OpenApiDocument, error) {
+	var doc OpenApiDocument
+	var root yaml.Node
+
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return doc, errors.New("cannot unmarshal into an open api document. Please check the input.")
+	}
+
+	externalSchemas, err := resolveRefs(&root, fileName)
+	if err != nil {
+		return doc, err
+	}
+
+	// the malformed nodes are reported as is, the invalid values with their reason
+	var typeErr *yaml.TypeError
+	if err := root.Decode(&doc); errors.As(err, &typeErr) {
+		return doc, errors.New("cannot unmarshal into an open api document. Please check the input.")
+	} else if err != nil {
+		return doc, fmt.Errorf("invalid open api document : %w", err)
+	}
+
+	doc.externalSchemas = make(map[string]*Schema, len(externalSchemas))
+	for ref, node := range externalSchemas {
+		var schema Schema
+		if err := node.Decode(&schema); err != nil {
+			return doc, errors.New("cannot unmarshal into an open api document. Please check the input.")
+		}
+		doc.externalSchemas[ref] = &schema
+	}
+
+	if err := doc.resolveSchemaRefs(); err != nil {
+		return doc, err
+	}
+
+	if err := doc.compileHeaderPatterns(); err != nil {
+		return doc, err
+	}
+
+	doc.mergePathParameters()
+
+	if err := doc.validateParameters(); err != nil {
+		return doc, err
+	}
+
+	// fail early on unknown or circular dependencies
+	if _, err := doc.scheduleOperations(); err != nil {
+		return doc, err
+	}
+
+	return doc, nil
+}
+
 func (s IntegrationTestSuite) EndpointCount() int {
 	var count int
 	for _, path := range s.doc.Paths {
@@ -64,13 +149,33 @@ func (s IntegrationTestSuite) EndpointCount() int {
 	return count
 }
 
-func (s *IntegrationTestSuite) Run(t *testing.T, parameters RunParameters) {
+// Run tests the server against the specification, and reports the coverage of the documented status codes.
+func (s *IntegrationTestSuite) Run(t *testing.T, parameters RunParameters) CoverageReport {
+	run := newSuiteRun(s.doc, parameters)
+	defer run.cancel()
+
+	// dependencies were checked by the parsing
+	operations, _ := s.doc.scheduleOperations()
+
 	t.Run(fmt.Sprintf("api test for %s", s.doc.Info.Title), func(t *testing.T) {
-		for path, pathObject := range s.doc.Paths {
-			// TODO improve that: ensure there is only one "/"
-			pathObject.runTests(t, fmt.Sprintf("%s%s", parameters.URL, path))
+		run.runSuiteHooks(t)
+
+		// consecutive operations of the same path are grouped in one subtest
+		for start := 0; start < len(operations); {
+			path, servers := operations[start].path, operations[start].pathServers
+			var pathOperations []pathOperation
+			for ; start < len(operations) && operations[start].path == path; start++ {
+				pathOperations = append(pathOperations, operations[start].pathOperation)
+			}
+			runPathTests(t, run, path, servers, pathOperations)
+		}
+
+		for _, workflow := range s.workflows {
+			workflow.runTests(t, run, s.doc)
 		}
 	})
+
+	return run.report(s.doc.Info.Title)
 }
 
 func (s IntegrationTestSuite) String() string {
@@ -1,11 +1,17 @@
 package alitest_test
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
 	"testing"
 
 	"github.com/toolzup/alitest"
@@ -26,6 +32,18 @@ var wrongYaml string
 //go:embed dataset/simple_pet_specification.yaml
 var petSpec string
 
+//go:embed dataset/all_verbs_specification.yaml
+var allVerbsSpec string
+
+//go:embed dataset/status_codes_specification.yaml
+var statusCodesSpec string
+
+//go:embed dataset/cases_specification.yaml
+var casesSpec string
+
+//go:embed dataset/skip_specification.yaml
+var skipSpec string
+
 func TestParse(t *testing.T) {
 	type testCase struct {
 		description string
@@ -129,6 +147,7 @@ func TestRun(t *testing.T) {
 	handleGet := func(w http.ResponseWriter, r *http.Request) {
 		var err error
 		encoder := json.NewEncoder(w)
+		w.Header().Set("Content-Type", "application/json")
 
 		if r.URL.Path == "/pet/0a62b985-17b5-48ee-ae04-ae0c99cb1109" {
 			// Sucess case
@@ -170,11 +189,17 @@ func TestRun(t *testing.T) {
 		var pet Pet
 		decoder := json.NewDecoder(r.Body)
 		encoder := json.NewEncoder(w)
+		w.Header().Set("Content-Type", "application/json")
 		if r.URL.Path != "/pet" {
 			t.Fatalf("expect nil error, but got %v", err)
 			return
 		}
 
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Fatalf("expect application/json content type, but got %s", r.Header.Get("Content-Type"))
+			return
+		}
+
 		err = decoder.Decode(&pet)
 		if err != nil {
 			t.Fatalf("expect nil error, but got %v", err)
@@ -186,6 +211,9 @@ func TestRun(t *testing.T) {
 			return
 		}
 
+		w.Header().Set("Location", "/pet/321654")
+		w.Header().Set("X-Rate-Limit-Remaining", "99")
+		w.Header().Set("Cache-Control", "no-store")
 		w.WriteHeader(http.StatusCreated)
 
 		err = encoder.Encode(PetResult{
@@ -230,6 +258,316 @@ func TestRun(t *testing.T) {
 	}
 }
 
+// TestRunAllVerbs ensures every declared operation is executed with its own verb.
+func TestRunAllVerbs(t *testing.T) {
+	type Pet struct {
+		Name string `json:"name"`
+	}
+
+	calledVerbs := map[string]bool{}
+
+	integrationSuite, err := alitest.ParseString(allVerbsSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if integrationSuite.EndpointCount() != 8 {
+		t.Fatalf("expect %d endpoint in integration test suite, but got %d", 8, integrationSuite.EndpointCount())
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pet/medor" {
+			t.Errorf("expect /pet/medor, but got %s", r.URL.Path)
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("expect nil error, but got %v", err)
+		}
+
+		switch r.Method {
+		case http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodPatch:
+			if len(body) == 0 {
+				t.Errorf("expect a body for %s, but got none", r.Method)
+			}
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			if len(body) != 0 {
+				t.Errorf("expect no body for %s, but got %s", r.Method, string(body))
+			}
+		}
+
+		calledVerbs[r.Method] = true
+
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet, http.MethodPut, http.MethodPatch:
+			if err := json.NewEncoder(w).Encode(Pet{Name: "Medor"}); err != nil {
+				t.Errorf("expect nil error, but got %v", err)
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	integrationSuite.Run(t, alitest.RunParameters{URL: srv.URL})
+
+	for _, verb := range []string{
+		http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete,
+		http.MethodOptions, http.MethodHead, http.MethodPatch, http.MethodTrace,
+	} {
+		if !calledVerbs[verb] {
+			t.Errorf("%s operation not covered", verb)
+		}
+	}
+}
+
+// TestRunStatusCodes ensures exact codes, ranges and default responses are all executed, in order.
+func TestRunStatusCodes(t *testing.T) {
+	var calledPaths []string
+
+	integrationSuite, err := alitest.ParseString(statusCodesSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calledPaths = append(calledPaths, r.URL.Path)
+
+		switch r.URL.Path {
+		case "/pet/medor":
+			w.WriteHeader(http.StatusNoContent)
+		case "/pet/conflict":
+			w.WriteHeader(http.StatusConflict)
+		case "/pet/teapot":
+			w.WriteHeader(http.StatusTeapot)
+		case "/pet/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/pet/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	integrationSuite.Run(t, alitest.RunParameters{URL: srv.URL})
+
+	expectedPaths := []string{"/pet/medor", "/pet/conflict", "/pet/teapot", "/pet/unavailable", "/pet/broken"}
+
+	if strings.Join(calledPaths, ",") != strings.Join(expectedPaths, ",") {
+		t.Fatalf("expect calls %v, but got %v", expectedPaths, calledPaths)
+	}
+}
+
+// TestRunCases ensures each x-ali-cases entry is run as its own request.
+func TestRunCases(t *testing.T) {
+	var calls []string
+
+	integrationSuite, err := alitest.ParseString(casesSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var pet map[string]interface{}
+		encoder := json.NewEncoder(w)
+
+		err := json.NewDecoder(r.Body).Decode(&pet)
+		if err != nil {
+			t.Errorf("expect nil error, but got %v", err)
+		}
+
+		calls = append(calls, r.URL.Path)
+
+		if r.URL.Path == "/pet/bad-format" {
+			w.WriteHeader(http.StatusBadRequest)
+			err = encoder.Encode(map[string]string{"type": "BadIDFormat"})
+		} else if name, _ := pet["name"].(string); name == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			err = encoder.Encode(map[string]string{"type": "InvalidPet", "description": "name is required"})
+		} else {
+			err = encoder.Encode(pet)
+		}
+
+		if err != nil {
+			t.Errorf("expect nil error, but got %v", err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	integrationSuite.Run(t, alitest.RunParameters{URL: srv.URL})
+
+	expectedCalls := []string{"/pet/medor", "/pet/medor", "/pet/medor", "/pet/bad-format"}
+
+	if strings.Join(calls, ",") != strings.Join(expectedCalls, ",") {
+		t.Fatalf("expect calls %v, but got %v", expectedCalls, calls)
+	}
+}
+
+// TestRunHooks ensures the suite and operation hooks surround the requests, with the resolved parameters.
+func TestRunHooks(t *testing.T) {
+	var events []string
+
+	integrationSuite, err := alitest.ParseString(casesSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var pet map[string]interface{}
+		encoder := json.NewEncoder(w)
+
+		err := json.NewDecoder(r.Body).Decode(&pet)
+		if err != nil {
+			t.Errorf("expect nil error, but got %v", err)
+		}
+
+		events = append(events, "request "+r.URL.Path)
+
+		if r.URL.Path == "/pet/bad-format" {
+			w.WriteHeader(http.StatusBadRequest)
+			err = encoder.Encode(map[string]string{"type": "BadIDFormat"})
+		} else if name, _ := pet["name"].(string); name == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			err = encoder.Encode(map[string]string{"type": "InvalidPet"})
+		} else {
+			err = encoder.Encode(pet)
+		}
+
+		if err != nil {
+			t.Errorf("expect nil error, but got %v", err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	operationHook := func(name string) alitest.OperationHook {
+		return func(ctx context.Context, parameters alitest.OperationHookParameters) error {
+			if ctx == nil {
+				t.Errorf("expect a context for %s", name)
+			}
+			events = append(events, fmt.Sprintf("%s %s %s %v", name, parameters.OperationID, parameters.StatusCode, parameters.Parameters["petId"].Value))
+			return nil
+		}
+	}
+
+	integrationSuite.Run(t, alitest.RunParameters{
+		URL: srv.URL,
+		BeforeSuite: func(ctx context.Context) error {
+			events = append(events, "before suite")
+			return nil
+		},
+		AfterSuite: func(ctx context.Context) error {
+			events = append(events, "after suite")
+			return nil
+		},
+		BeforeOperation: map[string]alitest.OperationHook{"updatePet": operationHook("before")},
+		AfterOperation:  map[string]alitest.OperationHook{"updatePet": operationHook("after")},
+	})
+
+	expectedEvents := []string{
+		"before suite",
+		"before updatePet 200 medor", "request /pet/medor", "after updatePet 200 medor",
+		"before updatePet 400 medor", "request /pet/medor", "after updatePet 400 medor",
+		"before updatePet 400 medor", "request /pet/medor", "after updatePet 400 medor",
+		"before updatePet 400 bad-format", "request /pet/bad-format", "after updatePet 400 bad-format",
+		"after suite",
+	}
+
+	if strings.Join(events, "\n") != strings.Join(expectedEvents, "\n") {
+		t.Fatalf("expect events %v, but got %v", expectedEvents, events)
+	}
+}
+
+// TestRunStrictSkip ensures skipped operations and responses send no request, even in strict mode.
+func TestRunStrictSkip(t *testing.T) {
+	var calls []string
+
+	integrationSuite, err := alitest.ParseString(skipSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if _, err := w.Write([]byte(`[]`)); err != nil {
+			t.Errorf("expect nil error, but got %v", err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	integrationSuite.Run(t, alitest.RunParameters{URL: srv.URL, Strict: true})
+
+	if strings.Join(calls, ",") != "GET /pets" {
+		t.Fatalf("expect only GET /pets to be called, but got %v", calls)
+	}
+}
+
+func TestRunStrictNoTestData(t *testing.T) {
+	output := runFailingTest(t, func(t *testing.T) {
+		integrationSuite, err := alitest.ParseString(`
+openapi: 3.0.1
+info:
+  title: strict
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      responses:
+        200:
+          description: no test data
+`)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var calls int
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+		})
+
+		report := integrationSuite.Run(t, alitest.RunParameters{Handler: handler, Strict: true})
+
+		t.Logf("calls: %d, status: %s, coverage: %.2f", calls, report.Entries[0].Status, report.Coverage)
+	})
+
+	if !strings.Contains(output, "Strict mode: no test data for listPets (200)") {
+		t.Errorf("expect the strict mode failure in the output, but got :\n%s", output)
+	}
+
+	if !strings.Contains(output, "calls: 0, status: errored, coverage: 0.00") {
+		t.Errorf("expect no request and an errored status, but got :\n%s", output)
+	}
+}
+
+// failingTestEnv names the test run by runFailingTest in the child process.
+const failingTestEnv = "ALITEST_FAILING_TEST"
+
+// runFailingTest runs the body of the calling test in a child test process, and returns its output.
+// The body is expected to fail, which would otherwise fail the calling test.
+func runFailingTest(t *testing.T, body func(t *testing.T)) string {
+	t.Helper()
+
+	if os.Getenv(failingTestEnv) == t.Name() {
+		body(t)
+		t.SkipNow()
+	}
+
+	command := exec.Command(os.Args[0], "-test.run=^"+t.Name()+"$", "-test.v")
+	command.Env = append(os.Environ(), failingTestEnv+"="+t.Name())
+	output, err := command.CombinedOutput()
+
+	if err == nil {
+		t.Fatalf("expect %s to fail, but it passed :\n%s", t.Name(), output)
+	}
+	return string(output)
+}
+
 // Je veux pouvoir lancer tous les tests avec des valeurs par défaut
 // Je veux un moyen pratique, facile et maintenable d'injecter des valeurs d'input
 // Je veux pouvoir faire une vérification simple des valeurs de retour
@@ -0,0 +1,654 @@
+package alitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Success criterion types.
+const (
+	SimpleCriterion = "simple"
+	RegexCriterion  = "regex"
+)
+
+var (
+	// embeddedExpressionPattern matches a {$expression} runtime expression embedded in a string.
+	embeddedExpressionPattern = regexp.MustCompile(`\{(\$[^}]+)\}`)
+	// comparisonPattern matches a simple condition comparing two operands.
+	comparisonPattern = regexp.MustCompile(`^\s*(.+?)\s*(==|!=|<=|>=|<|>)\s*(.+?)\s*$`)
+	// statusCodeConditionPattern matches a simple condition on the returned status code.
+	statusCodeConditionPattern = regexp.MustCompile(`^\s*\$statusCode\s*==\s*(\d{3})\s*$`)
+)
+
+// ArazzoDocument is an OpenAPI Arazzo document, describing workflows over the operations of the suite.
+type ArazzoDocument struct {
+	Arazzo             string                    `json:"arazzo" yaml:"arazzo"`
+	Info               ApiInfo                   `json:"info" yaml:"info"`
+	SourceDescriptions []ArazzoSourceDescription `json:"sourceDescriptions" yaml:"sourceDescriptions"`
+	Workflows          []ArazzoWorkflow          `json:"workflows" yaml:"workflows"`
+}
+
+// ArazzoSourceDescription names an api description used by the workflows.
+// The operations are always looked up in the specification of the suite.
+type ArazzoSourceDescription struct {
+	Name string `json:"name" yaml:"name"`
+	URL  string `json:"url" yaml:"url"`
+	Type string `json:"type" yaml:"type"`
+}
+
+type ArazzoWorkflow struct {
+	WorkflowID  string `json:"workflowId" yaml:"workflowId"`
+	Summary     string `json:"summary" yaml:"summary"`
+	Description string `json:"description" yaml:"description"`
+	// Inputs is the schema of the workflow inputs, given by RunParameters.WorkflowInputs
+	Inputs  *Schema           `json:"inputs" yaml:"inputs"`
+	Steps   []ArazzoStep      `json:"steps" yaml:"steps"`
+	Outputs map[string]string `json:"outputs" yaml:"outputs"`
+}
+
+// ArazzoStep calls an operation of the specification. Only operationId steps are supported.
+type ArazzoStep struct {
+	StepID          string             `json:"stepId" yaml:"stepId"`
+	Description     string             `json:"description" yaml:"description"`
+	OperationID     string             `json:"operationId" yaml:"operationId"`
+	OperationPath   string             `json:"operationPath" yaml:"operationPath"`
+	WorkflowID      string             `json:"workflowId" yaml:"workflowId"`
+	Parameters      []ArazzoParameter  `json:"parameters" yaml:"parameters"`
+	RequestBody     *ArazzoRequestBody `json:"requestBody" yaml:"requestBody"`
+	SuccessCriteria []ArazzoCriterion  `json:"successCriteria" yaml:"successCriteria"`
+	// Outputs are runtime expressions evaluated on the response, available to the next steps
+	Outputs map[string]string `json:"outputs" yaml:"outputs"`
+}
+
+type ArazzoParameter struct {
+	Name  string      `json:"name" yaml:"name"`
+	In    string      `json:"in" yaml:"in"`
+	Value interface{} `json:"value" yaml:"value"`
+}
+
+type ArazzoRequestBody struct {
+	ContentType string      `json:"contentType" yaml:"contentType"`
+	Payload     interface{} `json:"payload" yaml:"payload"`
+}
+
+// ArazzoCriterion is a success criterion of a step: a simple condition, or a regular expression
+// matched against the context value.
+type ArazzoCriterion struct {
+	Context   string `json:"context" yaml:"context"`
+	Condition string `json:"condition" yaml:"condition"`
+	Type      string `json:"type" yaml:"type"`
+}
+
+// LoadWorkflowsFile adds the workflows of an Arazzo document to the suite, they run after the operation tests.
+func (s *IntegrationTestSuite) LoadWorkflowsFile(fileName string) error {
+	content, err := os.ReadFile(fileName)
+
+	if err != nil {
+		return err
+	}
+
+	return s.loadWorkflows(content, fileName)
+}
+
+// LoadWorkflowsString adds the workflows of an Arazzo document to the suite, they run after the operation tests.
+func (s *IntegrationTestSuite) LoadWorkflowsString(content string) error {
+	return s.loadWorkflows([]byte(content), "")
+}
+
+func (s *IntegrationTestSuite) loadWorkflows(content []byte, fileName string) error {
+	var doc ArazzoDocument
+	var root yaml.Node
+
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return errors.New("cannot unmarshal into an arazzo document. Please check the input.")
+	}
+
+	// an arazzo document has no schema to link
+	if _, err := resolveRefs(&root, fileName); err != nil {
+		return err
+	}
+
+	if err := root.Decode(&doc); err != nil {
+		return errors.New("cannot unmarshal into an arazzo document. Please check the input.")
+	}
+
+	workflowIDs := map[string]bool{}
+	for _, workflow := range s.workflows {
+		workflowIDs[workflow.WorkflowID] = true
+	}
+
+	for _, workflow := range doc.Workflows {
+		if workflow.WorkflowID == "" {
+			return errors.New("a workflow has no workflowId")
+		}
+		if workflowIDs[workflow.WorkflowID] {
+			return fmt.Errorf("duplicated workflow %s", workflow.WorkflowID)
+		}
+		workflowIDs[workflow.WorkflowID] = true
+
+		if err := s.doc.checkWorkflow(workflow); err != nil {
+			return fmt.Errorf("invalid workflow %s : %w", workflow.WorkflowID, err)
+		}
+	}
+
+	s.workflows = append(s.workflows, doc.Workflows...)
+	return nil
+}
+
+// checkWorkflow ensures the steps of a workflow can be run against the document.
+func (d OpenApiDocument) checkWorkflow(workflow ArazzoWorkflow) error {
+	stepIDs := map[string]bool{}
+	for _, step := range workflow.Steps {
+		if step.StepID == "" {
+			return errors.New("a step has no stepId")
+		}
+		if stepIDs[step.StepID] {
+			return fmt.Errorf("duplicated step %s", step.StepID)
+		}
+		stepIDs[step.StepID] = true
+
+		if step.OperationID == "" {
+			return fmt.Errorf("step %s has no operationId, operationPath and workflowId steps are not supported", step.StepID)
+		}
+		target, err := d.findOperation(step.operationID())
+		if err != nil {
+			return fmt.Errorf("step %s calls the %w", step.StepID, err)
+		}
+
+		for _, parameter := range step.Parameters {
+			if _, _, err := target.stepParameter(parameter); err != nil {
+				return fmt.Errorf("step %s : %w", step.StepID, err)
+			}
+		}
+
+		for _, criterion := range step.SuccessCriteria {
+			if criterion.Type != "" && criterion.Type != SimpleCriterion && criterion.Type != RegexCriterion {
+				return fmt.Errorf("step %s has an unsupported %s success criterion", step.StepID, criterion.Type)
+			}
+			if criterion.Type == RegexCriterion && criterion.Context == "" {
+				return fmt.Errorf("step %s has a regex success criterion without context", step.StepID)
+			}
+		}
+	}
+	return nil
+}
+
+// findOperation returns the operation with the given operationId.
+func (d OpenApiDocument) findOperation(operationID string) (scheduledOperation, error) {
+	var found []scheduledOperation
+	for _, path := range sortedKeys(d.Paths) {
+		for _, op := range d.Paths[path].operations() {
+			if op.operation.OperationID == operationID {
+				found = append(found, scheduledOperation{path: path, pathServers: d.Paths[path].Servers, pathOperation: op})
+			}
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return scheduledOperation{}, fmt.Errorf("unknown operation %s", operationID)
+	case 1:
+		return found[0], nil
+	}
+	return scheduledOperation{}, fmt.Errorf("operation %s, which is not unique", operationID)
+}
+
+// stepParameter returns the operation parameter set by a step parameter, and whether the operation
+// declares it. A parameter located by in, and not declared, is sent as an undocumented parameter.
+func (o scheduledOperation) stepParameter(parameter ArazzoParameter) (OpenApiParameter, bool, error) {
+	var location ParameterLocation
+	if parameter.In != "" {
+		var err error
+		if location, err = parseParameterLocation(parameter.In); err != nil {
+			return OpenApiParameter{}, false, err
+		}
+	}
+
+	for _, declared := range o.operation.Parameters {
+		if declared.Name != parameter.Name {
+			continue
+		}
+		if parameter.In != "" && declared.In != location {
+			return OpenApiParameter{}, false, fmt.Errorf("parameter %s is in %s, not in %s", parameter.Name, strings.ToLower(declared.In.String()), parameter.In)
+		}
+		return declared, true, nil
+	}
+
+	if parameter.In == "" {
+		return OpenApiParameter{}, false, fmt.Errorf("parameter %s is not declared by %s, set its location with in", parameter.Name, o.operation.OperationID)
+	}
+	return OpenApiParameter{Name: parameter.Name, In: location}, false, nil
+}
+
+// operationID returns the operationId of the step, without its source description qualifier.
+func (s ArazzoStep) operationID() string {
+	if !strings.HasPrefix(s.OperationID, "$sourceDescriptions.") {
+		return s.OperationID
+	}
+	return s.OperationID[strings.LastIndex(s.OperationID, ".")+1:]
+}
+
+// expectedStatusCode returns the documented status code matching the $statusCode criterion of the step,
+// or the first documented success status code when there is none.
+func (s ArazzoStep) expectedStatusCode(responses OpenApiResponses) (string, error) {
+	for _, criterion := range s.SuccessCriteria {
+		if criterion.Type != "" && criterion.Type != SimpleCriterion {
+			continue
+		}
+		match := statusCodeConditionPattern.FindStringSubmatch(criterion.Condition)
+		if match == nil {
+			continue
+		}
+		for _, statusCode := range []string{match[1], match[1][:1] + "XX", DefaultResponse} {
+			if _, found := responses[statusCode]; found {
+				return statusCode, nil
+			}
+		}
+		return "", fmt.Errorf("status %s is not documented", match[1])
+	}
+
+	for _, statusCode := range responses.StatusCodes() {
+		if statusCode[0] == '2' {
+			return statusCode, nil
+		}
+	}
+	return "", errors.New("no documented success status, add a $statusCode success criterion")
+}
+
+// workflowRun holds the inputs of a running workflow, and the outputs of its steps.
+type workflowRun struct {
+	inputs map[string]interface{}
+	steps  map[string]map[string]interface{}
+}
+
+// stepResult is the exchange of a step, used to evaluate the runtime expressions.
+type stepResult struct {
+	url        string
+	method     string
+	statusCode int
+	header     http.Header
+	body       interface{}
+}
+
+func newStepResult(request *http.Request, response *http.Response, payload []byte) *stepResult {
+	result := &stepResult{url: request.URL.String(), method: request.Method, statusCode: response.StatusCode, header: response.Header}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&result.body); err != nil {
+		result.body = string(payload)
+	}
+	return result
+}
+
+func (w ArazzoWorkflow) runTests(t *testing.T, run *suiteRun, doc OpenApiDocument) {
+	t.Run(w.WorkflowID, func(t *testing.T) {
+		inputs := run.parameters.WorkflowInputs[w.WorkflowID]
+		if inputs == nil {
+			inputs = map[string]interface{}{}
+		}
+
+		if w.Inputs != nil {
+			if violations := w.Inputs.Validate(normalizeJSON(inputs)); len(violations) > 0 {
+				t.Fatalf("Invalid inputs for the workflow %s, set them in RunParameters.WorkflowInputs :\n%s", w.WorkflowID, joinViolations(violations))
+			}
+		}
+
+		workflow := &workflowRun{inputs: inputs, steps: map[string]map[string]interface{}{}}
+
+		for _, step := range w.Steps {
+			target, _ := doc.findOperation(step.operationID())
+			passed := t.Run(step.StepID, func(t *testing.T) {
+				workflow.runStep(t, run, step, target)
+			})
+			if !passed {
+				t.Fatalf("The workflow %s ends at the failed step %s", w.WorkflowID, step.StepID)
+			}
+		}
+
+		for _, name := range sortedKeys(w.Outputs) {
+			value, err := workflow.evaluate(w.Outputs[name], nil)
+			if err != nil {
+				t.Fatalf("Cannot evaluate the output %s of the workflow %s : %v", name, w.WorkflowID, err)
+			}
+			t.Logf("Workflow output %s = %v", name, value)
+		}
+	})
+}
+
+// runStep sends the request of the step, with the same checks as the operation tests,
+// then checks the success criteria and evaluates the outputs.
+func (w *workflowRun) runStep(t *testing.T, run *suiteRun, step ArazzoStep, target scheduledOperation) {
+	url, err := run.url(target.path, target.pathServers, target.operation.Servers)
+
+	if err != nil {
+		t.Fatalf("Cannot resolve the server of step %s : %v", step.StepID, err)
+	}
+
+	ctx := newOperationRunContext(run, target.path, url, target.verb, *target.operation)
+
+	statusCode, err := step.expectedStatusCode(ctx.responses)
+
+	if err != nil {
+		t.Fatalf("Cannot tell the expected status of step %s : %v", step.StepID, err)
+	}
+
+	documented := ctx.responses[statusCode]
+	response := OpenApiResponse{Description: documented.Description, Content: documented.Content, Headers: documented.Headers, AliParameters: map[string]AliParameter{}}
+
+	for _, parameter := range step.Parameters {
+		value, err := w.expand(parameter.Value, nil)
+		if err != nil {
+			t.Fatalf("Cannot evaluate the parameter %s of step %s : %v", parameter.Name, step.StepID, err)
+		}
+		response.AliParameters[parameter.Name] = AliParameter{Value: value}
+
+		// the undocumented parameters are sent too
+		if operationParameter, declared, _ := target.stepParameter(parameter); !declared {
+			ctx.parameters = append(slices.Clip(ctx.parameters), operationParameter)
+		}
+	}
+
+	if step.RequestBody != nil {
+		response.AliBody, err = w.expand(step.RequestBody.Payload, nil)
+		if err != nil {
+			t.Fatalf("Cannot evaluate the request body of step %s : %v", step.StepID, err)
+		}
+		if step.RequestBody.ContentType != "" && ctx.requestBody != nil {
+			ctx.requestBody = &OpenApiRequestBody{
+				Required: ctx.requestBody.Required,
+				Content:  map[string]OpenApiResponseContent{step.RequestBody.ContentType: ctx.requestBody.Content[step.RequestBody.ContentType]},
+			}
+		}
+	}
+
+	// a middleware may return a response without its request, the sent request is used instead
+	request, httpResponse, payload := response.exchange(t, ctx, statusCode)
+	result := newStepResult(request, httpResponse, payload)
+
+	for _, criterion := range step.SuccessCriteria {
+		met, err := w.check(criterion, result)
+		if err != nil {
+			t.Fatalf("Cannot evaluate the success criterion %q of step %s : %v", criterion.Condition, step.StepID, err)
+		}
+		if !met {
+			t.Errorf("Step %s does not meet the success criterion %q, for the returned payload %s", step.StepID, criterion.Condition, string(payload))
+		}
+	}
+
+	outputs := map[string]interface{}{}
+	for _, name := range sortedKeys(step.Outputs) {
+		value, err := w.evaluate(step.Outputs[name], result)
+		if err != nil {
+			t.Fatalf("Cannot evaluate the output %s of step %s : %v", name, step.StepID, err)
+		}
+		outputs[name] = value
+	}
+	w.steps[step.StepID] = outputs
+}
+
+// expand copies a value, replacing its runtime expressions: a string starting with $ is
+// an expression, and {$expression} can be embedded in a string.
+func (w *workflowRun) expand(value interface{}, result *stepResult) (interface{}, error) {
+	return transformStrings(value, func(value string) (interface{}, error) {
+		if strings.HasPrefix(value, "$") {
+			return w.evaluate(value, result)
+		}
+
+		var err error
+		expanded := embeddedExpressionPattern.ReplaceAllStringFunc(value, func(embedded string) string {
+			evaluated, evaluationErr := w.evaluate(embedded[1:len(embedded)-1], result)
+			if evaluationErr != nil && err == nil {
+				err = evaluationErr
+			}
+			return variableString(evaluated)
+		})
+		return expanded, err
+	})
+}
+
+// evaluate computes a runtime expression. The response expressions need the result of the step.
+func (w *workflowRun) evaluate(expression string, result *stepResult) (interface{}, error) {
+	expression = strings.TrimSpace(expression)
+
+	if strings.HasPrefix(expression, "$inputs.") {
+		name := strings.TrimPrefix(expression, "$inputs.")
+		value, found := w.inputs[name]
+		if !found {
+			return nil, fmt.Errorf("unknown input %s", name)
+		}
+		return value, nil
+	}
+
+	if strings.HasPrefix(expression, "$steps.") {
+		stepID, name, found := strings.Cut(strings.TrimPrefix(expression, "$steps."), ".outputs.")
+		outputs, run := w.steps[stepID]
+		if !found {
+			return nil, fmt.Errorf("unsupported runtime expression %s, expect $steps.<stepId>.outputs.<name>", expression)
+		}
+		if !run {
+			return nil, fmt.Errorf("step %s has no outputs yet", stepID)
+		}
+		value, found := outputs[name]
+		if !found {
+			return nil, fmt.Errorf("step %s has no output %s", stepID, name)
+		}
+		return value, nil
+	}
+
+	isResponseExpression := expression == "$statusCode" || expression == "$url" || expression == "$method" || strings.HasPrefix(expression, "$response.")
+	if isResponseExpression && result == nil {
+		return nil, fmt.Errorf("%s is only available once the request is sent", expression)
+	}
+
+	switch {
+	case expression == "$statusCode":
+		return result.statusCode, nil
+	case expression == "$url":
+		return result.url, nil
+	case expression == "$method":
+		return result.method, nil
+	case expression == "$response.body":
+		return result.body, nil
+	case strings.HasPrefix(expression, "$response.body#"):
+		tokens, err := parseJSONPointer(strings.TrimPrefix(expression, "$response.body#"))
+		if err != nil {
+			return nil, err
+		}
+		value, found := lookupJSONPointer(result.body, tokens)
+		if !found {
+			return nil, fmt.Errorf("nothing at %s in the response", strings.TrimPrefix(expression, "$response.body#"))
+		}
+		return value, nil
+	case strings.HasPrefix(expression, "$response.header."):
+		name := strings.TrimPrefix(expression, "$response.header.")
+		values, found := result.header[http.CanonicalHeaderKey(name)]
+		if !found {
+			return nil, fmt.Errorf("header %s is missing", name)
+		}
+		return values[0], nil
+	}
+
+	return nil, fmt.Errorf("unsupported runtime expression %s", expression)
+}
+
+// check evaluates a success criterion on the result of a step.
+func (w *workflowRun) check(criterion ArazzoCriterion, result *stepResult) (bool, error) {
+	if criterion.Type == RegexCriterion {
+		value, err := w.evaluate(criterion.Context, result)
+		if err != nil {
+			return false, err
+		}
+		pattern, err := regexp.Compile(criterion.Condition)
+		if err != nil {
+			return false, err
+		}
+		return pattern.MatchString(variableString(value)), nil
+	}
+
+	parser := &conditionParser{condition: criterion.Condition, run: w, result: result}
+	met, err := parser.or()
+	if err != nil {
+		return false, err
+	}
+	if parser.skipSpaces(); parser.position < len(parser.condition) {
+		return false, fmt.Errorf("unexpected %q", parser.condition[parser.position:])
+	}
+	return met, nil
+}
+
+// conditionParser evaluates a simple condition: comparisons combined with !, && and ||,
+// and grouped with parentheses. ! binds tighter than &&, which binds tighter than ||.
+type conditionParser struct {
+	condition string
+	position  int
+	run       *workflowRun
+	result    *stepResult
+}
+
+func (p *conditionParser) or() (bool, error) {
+	met, err := p.and()
+	for err == nil && p.consume("||") {
+		var alternative bool
+		alternative, err = p.and()
+		met = met || alternative
+	}
+	return met, err
+}
+
+func (p *conditionParser) and() (bool, error) {
+	met, err := p.not()
+	for err == nil && p.consume("&&") {
+		var other bool
+		other, err = p.not()
+		met = met && other
+	}
+	return met, err
+}
+
+func (p *conditionParser) not() (bool, error) {
+	if p.skipSpaces(); strings.HasPrefix(p.condition[p.position:], "!") && !strings.HasPrefix(p.condition[p.position:], "!=") {
+		p.position++
+		met, err := p.not()
+		return !met, err
+	}
+
+	if p.consume("(") {
+		met, err := p.or()
+		if err == nil && !p.consume(")") {
+			err = fmt.Errorf("missing ) in %q", p.condition)
+		}
+		return met, err
+	}
+
+	return p.comparison()
+}
+
+// comparison evaluates the comparison up to the next &&, || or ), outside of the quoted strings.
+func (p *conditionParser) comparison() (bool, error) {
+	start, quoted := p.position, false
+	for ; p.position < len(p.condition); p.position++ {
+		rest := p.condition[p.position:]
+		if rest[0] == '\'' {
+			quoted = !quoted
+		}
+		if !quoted && (rest[0] == ')' || strings.HasPrefix(rest, "&&") || strings.HasPrefix(rest, "||")) {
+			break
+		}
+	}
+	if quoted {
+		return false, fmt.Errorf("unterminated string in %q", p.condition)
+	}
+	return p.run.compare(p.condition[start:p.position], p.result)
+}
+
+// consume skips the token when it is next in the condition.
+func (p *conditionParser) consume(token string) bool {
+	p.skipSpaces()
+	if !strings.HasPrefix(p.condition[p.position:], token) {
+		return false
+	}
+	p.position += len(token)
+	return true
+}
+
+func (p *conditionParser) skipSpaces() {
+	for p.position < len(p.condition) && (p.condition[p.position] == ' ' || p.condition[p.position] == '\t') {
+		p.position++
+	}
+}
+
+// compare evaluates a simple condition comparing two operands.
+func (w *workflowRun) compare(condition string, result *stepResult) (bool, error) {
+	match := comparisonPattern.FindStringSubmatch(condition)
+	if match == nil {
+		return false, fmt.Errorf("%q is not a comparison", strings.TrimSpace(condition))
+	}
+
+	left, err := w.operand(match[1], result)
+	if err != nil {
+		return false, err
+	}
+	right, err := w.operand(match[3], result)
+	if err != nil {
+		return false, err
+	}
+
+	left, right = normalizeJSON(left), normalizeJSON(right)
+	leftNumber, leftIsNumber := left.(float64)
+	rightNumber, rightIsNumber := right.(float64)
+
+	switch match[2] {
+	case "==":
+		return reflect.DeepEqual(left, right), nil
+	case "!=":
+		return !reflect.DeepEqual(left, right), nil
+	}
+
+	if !leftIsNumber || !rightIsNumber {
+		return false, fmt.Errorf("cannot compare %v and %v with %s, expect numbers", left, right, match[2])
+	}
+
+	switch match[2] {
+	case "<":
+		return leftNumber < rightNumber, nil
+	case "<=":
+		return leftNumber <= rightNumber, nil
+	case ">":
+		return leftNumber > rightNumber, nil
+	default:
+		return leftNumber >= rightNumber, nil
+	}
+}
+
+// operand evaluates a runtime expression, or a literal: a 'quoted' string, a number, true, false or null.
+func (w *workflowRun) operand(operand string, result *stepResult) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(operand, "$"):
+		return w.evaluate(operand, result)
+	case len(operand) >= 2 && strings.HasPrefix(operand, "'") && strings.HasSuffix(operand, "'"):
+		return operand[1 : len(operand)-1], nil
+	case operand == "true" || operand == "false":
+		return operand == "true", nil
+	case operand == "null":
+		return nil, nil
+	}
+
+	number, err := strconv.ParseFloat(operand, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported operand %s", operand)
+	}
+	return number, nil
+}
@@ -0,0 +1,255 @@
+package alitest_test
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/toolzup/alitest"
+)
+
+//go:embed dataset/pet_workflows.arazzo.yaml
+var petWorkflows string
+
+func TestRunWorkflows(t *testing.T) {
+	var calls []string
+
+	integrationSuite, err := alitest.ParseString(captureSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := integrationSuite.LoadWorkflowsString(petWorkflows); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var pet struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&pet); err != nil {
+			t.Errorf("expect nil error, but got %v", err)
+		}
+		calls = append(calls, strings.TrimSpace(fmt.Sprintf("%s %s %s %s", r.Method, r.URL.RequestURI(), pet.Name, r.Header.Get("X-Request-Id"))))
+		w.Header().Set("Content-Type", "application/json")
+
+		var err error
+		switch r.Method {
+		case http.MethodPost:
+			w.Header().Set("Location", "/pets/12345678")
+			w.WriteHeader(http.StatusCreated)
+			_, err = fmt.Fprintf(w, `{"id": 12345678, "name": %q}`, pet.Name)
+		case http.MethodPatch:
+			_, err = fmt.Fprintf(w, `{"id": 12345678, "name": %q, "location": "/pets/12345678?renamed=true"}`, pet.Name)
+		}
+		if err != nil {
+			t.Errorf("expect nil error, but got %v", err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	integrationSuite.Run(t, alitest.RunParameters{
+		URL:            srv.URL,
+		WorkflowInputs: map[string]map[string]interface{}{"createAndRenamePet": {"name": "Medor"}},
+	})
+
+	expected := []string{
+		"POST /pets Medor",
+		"PATCH /pets?id=12345678 Rex",
+		"POST /pets Medor",
+		"PATCH /pets?id=12345678 Medor the second rename-request",
+	}
+
+	if strings.Join(calls, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expect calls %v, but got %v", expected, calls)
+	}
+}
+
+func TestRunWorkflowsCriteria(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		err       string
+	}{
+		{
+			name:      "grouped alternatives",
+			condition: "$statusCode == 200 && ($response.body#/name == 'Rex' || $response.body#/id < 0)",
+			err:       "does not meet the success criterion",
+		},
+		{
+			name:      "negation",
+			condition: "!($statusCode == 200)",
+			err:       "does not meet the success criterion",
+		},
+		{
+			name:      "missing parenthesis",
+			condition: "($statusCode == 200",
+			err:       "missing ) in",
+		},
+		{
+			name:      "unterminated string",
+			condition: "$response.body#/name == 'Rex",
+			err:       "unterminated string in",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			workflows := strings.Replace(petWorkflows, "          - condition: '!($response.body#/id <= 0) && !$statusCode == 201'\n", "          - condition: "+strconv.Quote(test.condition)+"\n", 1)
+
+			output := runFailingTest(t, func(t *testing.T) {
+				integrationSuite, err := alitest.ParseString(captureSpec)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err := integrationSuite.LoadWorkflowsString(workflows); err != nil {
+					t.Fatal(err)
+				}
+
+				handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					if r.Method == http.MethodPost {
+						w.Header().Set("Location", "/pets/12345678")
+						w.WriteHeader(http.StatusCreated)
+						_, _ = w.Write([]byte(`{"id": 12345678, "name": "Medor"}`))
+						return
+					}
+					_, _ = w.Write([]byte(`{"id": 12345678, "name": "Medor the second", "location": "/pets/12345678?renamed=true"}`))
+				})
+
+				integrationSuite.Run(t, alitest.RunParameters{
+					Handler:        handler,
+					WorkflowInputs: map[string]map[string]interface{}{"createAndRenamePet": {"name": "Medor"}},
+				})
+			})
+
+			if !strings.Contains(output, test.err) {
+				t.Fatalf("expect %q in the output, but got :\n%s", test.err, output)
+			}
+		})
+	}
+}
+
+// TestRunWorkflowsStubResponse ensures a step accepts a response without its request, as returned by a stub.
+func TestRunWorkflowsStubResponse(t *testing.T) {
+	integrationSuite, err := alitest.ParseString(captureSpec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := integrationSuite.LoadWorkflowsString(petWorkflows); err != nil {
+		t.Fatal(err)
+	}
+
+	stub := func(next http.RoundTripper) http.RoundTripper {
+		return alitest.RoundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			var pet struct {
+				Name string `json:"name"`
+			}
+			if err := json.NewDecoder(request.Body).Decode(&pet); err != nil {
+				return nil, err
+			}
+
+			// http.Client leaves the request of the response unset
+			response := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(fmt.Sprintf(`{"id": 12345678, "name": %q, "location": "/pets/12345678?renamed=true"}`, pet.Name))),
+			}
+			if request.Method == http.MethodPost {
+				response.StatusCode = http.StatusCreated
+				response.Header.Set("Location", "/pets/12345678")
+				response.Body = io.NopCloser(strings.NewReader(fmt.Sprintf(`{"id": 12345678, "name": %q}`, pet.Name)))
+			}
+			return response, nil
+		})
+	}
+
+	report := integrationSuite.Run(t, alitest.RunParameters{
+		URL:            "http://petstore.test",
+		Middlewares:    []alitest.Middleware{stub},
+		WorkflowInputs: map[string]map[string]interface{}{"createAndRenamePet": {"name": "Medor"}},
+	})
+
+	if report.Totals.Passed != 2 {
+		t.Fatalf("expect 2 passed responses, but got %+v", report.Totals)
+	}
+}
+
+func TestLoadWorkflows(t *testing.T) {
+	tests := []struct {
+		name      string
+		workflows string
+		err       string
+	}{
+		{
+			name:      "valid",
+			workflows: petWorkflows,
+		},
+		{
+			name:      "unknown operation",
+			workflows: strings.Replace(petWorkflows, "operationId: renamePet", "operationId: deletePet", 1),
+			err:       "invalid workflow createAndRenamePet : step renamePet calls the unknown operation deletePet",
+		},
+		{
+			name:      "parameter in another location",
+			workflows: strings.Replace(petWorkflows, "in: query", "in: header", 1),
+			err:       "invalid workflow createAndRenamePet : step renamePet : parameter id is in query, not in header",
+		},
+		{
+			name:      "undeclared parameter without location",
+			workflows: strings.Replace(petWorkflows, "            in: header\n", "", 1),
+			err:       "invalid workflow createAndRenamePet : step renamePet : parameter X-Request-Id is not declared by renamePet, set its location with in",
+		},
+		{
+			name:      "duplicated step",
+			workflows: strings.Replace(petWorkflows, "stepId: renamePet", "stepId: createPet", 1),
+			err:       "invalid workflow createAndRenamePet : duplicated step createPet",
+		},
+		{
+			name:      "unsupported criterion",
+			workflows: strings.Replace(petWorkflows, "type: regex", "type: jsonpath", 1),
+			err:       "invalid workflow createAndRenamePet : step createPet has an unsupported jsonpath success criterion",
+		},
+		{
+			name:      "workflow step",
+			workflows: strings.Replace(petWorkflows, "operationId: renamePet", "workflowId: renameWorkflow", 1),
+			err:       "invalid workflow createAndRenamePet : step renamePet has no operationId, operationPath and workflowId steps are not supported",
+		},
+		{
+			name:      "not an arazzo document",
+			workflows: "workflows: 5",
+			err:       "cannot unmarshal into an arazzo document. Please check the input.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			integrationSuite, err := alitest.ParseString(captureSpec)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = integrationSuite.LoadWorkflowsString(test.workflows)
+
+			if test.err == "" && err != nil {
+				t.Fatalf("expect nil error, got %v", err)
+			}
+
+			if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Fatalf("expect error %q, got %v", test.err, err)
+			}
+		})
+	}
+}